@@ -1,27 +1,55 @@
 package asar
 
 import (
+	"context"
+	"crypto/sha256"
 	"errors"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // CreateOptions 打包选项
 type CreateOptions struct {
-	Dot       bool
-	Ordering  string
-	Pattern   string
+	Dot      bool
+	Ordering string
+	// Pattern 包含模式，相对于来源根目录，支持 "**" 匹配任意层级；
+	// Pattern 与 Include 均为空时默认为 "/**/*"（全部包含）
+	Pattern string
+	// Include 包含模式列表，条目与 Pattern 或其中任一模式匹配即包含；
+	// 只设置 Include 时仅打包匹配的条目，如 {"dist/**", "package.json"}
+	Include []string
+	// Exclude 排除模式，匹配的文件被跳过，匹配的目录不再向下遍历；不含 '/' 的模式匹配任意层级的名称（如 "*.map"）
+	Exclude []string
+	// NoIgnoreFile 为 true 时不读取来源中的 .asarignore（gitignore 语法，可嵌套、支持 '!' 取反）
+	NoIgnoreFile bool
+	// Transform 对每个文件调用一次，参数为文件路径（目录打包时为绝对路径，fs.FS 打包时为 slash 相对路径）；
+	// 返回的内容替代原文件写入归档与 .unpacked，头中的 size、offset、integrity 均基于转换后的内容计算。
+	// 为 nil 或返回 nil 时表示不转换
 	Transform func(filePath string) io.ReadCloser
 	Unpack    string
 	UnpackDir string
+	// Compress 非空时，文件名匹配该模式（与 Unpack 相同的匹配规则）的打包文件以压缩形式存储，
+	// 头中记录算法与原始大小；读取与解包时透明解压，integrity 仍按原始内容计算。解包的文件不压缩
+	Compress string
+	// Compression 压缩算法：CompressionGzip（默认）或 CompressionDeflate
+	Compression string
+	// Context 取消时中止打包并删除已写入的临时文件，可配合 signal.NotifyContext 处理中断；nil 表示不可取消
+	Context context.Context
+	// Concurrency 同时读取、压缩并计算完整性的文件数，0 表示 runtime.GOMAXPROCS(0)；
+	// 各文件的偏移在写出前已确定，输出与串行打包完全一致
+	Concurrency int
 }
 
-// isUnpackedDir 判断目录是否匹配 unpackDir 规则（支持前缀或简易 glob）
-func isUnpackedDir(dirPath, pattern string, unpackDirs *[]string) bool {
-	if strings.HasPrefix(dirPath, pattern) || matchGlob(dirPath, pattern) {
+// isUnpackedDir 判断目录是否匹配 unpackDir 规则（支持前缀或 minimatch 兼容的 glob）
+func isUnpackedDir(dirPath, pattern string, g *Glob, unpackDirs *[]string) bool {
+	if strings.HasPrefix(dirPath, pattern) || g.Match(dirPath) {
 		if !contains(*unpackDirs, dirPath) {
 			*unpackDirs = append(*unpackDirs, dirPath)
 		}
@@ -35,6 +63,55 @@ func isUnpackedDir(dirPath, pattern string, unpackDirs *[]string) bool {
 	return false
 }
 
+// unpackRules 按 CreateOptions 的 Unpack 与 UnpackDir 判断条目是否解包，模式只编译一次；
+// 匹配 UnpackDir 的目录会被记录并使其子项一同解包，因此目录须先于其内容判断（与遍历顺序一致）
+type unpackRules struct {
+	unpack    *Glob // Unpack 为空时为 nil
+	unpackDir string
+	dirGlob   *Glob
+	dirs      []string
+}
+
+// newUnpackRules 编译 options 中的 Unpack 与 UnpackDir，模式展开过多时返回 ErrPatternTooLarge
+func newUnpackRules(options CreateOptions) (*unpackRules, error) {
+	u := &unpackRules{unpackDir: options.UnpackDir}
+	var err error
+	if u.unpack, err = compileBasePattern(options.Unpack); err != nil {
+		return nil, err
+	}
+	if options.UnpackDir != "" {
+		if u.dirGlob, err = ParseGlob(options.UnpackDir, GlobOptions{}); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+func (u *unpackRules) match(relativePath string, unpack *Glob) bool {
+	su := false
+	if unpack != nil {
+		su = unpack.Match(relativePath)
+	}
+	if !su && u.unpackDir != "" {
+		su = isUnpackedDir(relativePath, u.unpackDir, u.dirGlob, &u.dirs)
+	}
+	return su
+}
+
+// dir 判断目录是否解包
+func (u *unpackRules) dir(name string) bool { return u.match(name, nil) }
+
+// file 判断文件是否解包：文件名匹配 unpack，或所在目录匹配 unpack/unpackDir 时解包
+func (u *unpackRules) file(name string) bool {
+	if u.unpack != nil && u.unpack.Match(name) {
+		return true
+	}
+	return u.match(path.Dir(name), u.unpack)
+}
+
+// link 判断符号链接是否解包
+func (u *unpackRules) link(name string) bool { return u.match(name, u.unpack) }
+
 // CreatePackage 以默认选项打包目录
 func CreatePackage(src, dest string) error {
 	return CreatePackageWithOptions(src, dest, CreateOptions{})
@@ -42,152 +119,159 @@ func CreatePackage(src, dest string) error {
 
 // CreatePackageWithOptions 根据选项打包目录
 func CreatePackageWithOptions(src, dest string, options CreateOptions) error {
-	if options.Pattern == "" {
+	if options.Pattern == "" && len(options.Include) == 0 {
 		options.Pattern = "/**/*"
 	}
 	absSrc, _ := filepath.Abs(src)
-	includeDot := options.Dot
-	files, meta, err := Crawl(filepath.Join(absSrc), includeDot)
+	files, meta, err := CrawlWithOptions(absSrc, options)
 	if err != nil {
 		return err
 	}
 	return CreatePackageFromFiles(absSrc, dest, files, meta, options)
 }
 
-// CreatePackageFromFiles 从文件列表创建 ASAR
+// CreatePackageFromFS 打包任意 fs.FS（embed.FS、zip.Reader、内存文件系统等），
+// 来源实现 ReadLinkFS 时保留符号链接；unpack 与 ordering 语义与目录打包一致
+func CreatePackageFromFS(fsys fs.FS, dest string, options CreateOptions) error {
+	if options.Pattern == "" && len(options.Include) == 0 {
+		options.Pattern = "/**/*"
+	}
+	files, meta, err := CrawlFSWithOptions(fsys, options)
+	if err != nil {
+		return err
+	}
+	return CreatePackageFromFSFiles(fsys, dest, files, meta, options)
+}
+
+// CreatePackageFromFiles 从文件列表创建 ASAR，filenames 与 metadata 的键为 src 下的路径
 func CreatePackageFromFiles(src, dest string, filenames []string, metadata map[string]*CrawledFileType, options CreateOptions) error {
 	src, _ = filepath.Abs(src)
+	names := make([]string, 0, len(filenames))
+	meta := make(map[string]*CrawledFileType, len(metadata))
+	for _, f := range filenames {
+		f = filepath.Clean(f)
+		rel := relPath(src, f)
+		names = append(names, rel)
+		if m, ok := metadata[f]; ok {
+			meta[rel] = m
+		}
+	}
+	return CreatePackageFromFSFiles(dirFS(src), dest, names, meta, options)
+}
+
+// CreatePackageFromFSFiles 从 fs.FS 中的文件列表创建 ASAR，filenames 为 slash 相对路径（如 CrawlFS 的结果）
+func CreatePackageFromFSFiles(fsys fs.FS, dest string, filenames []string, metadata map[string]*CrawledFileType, options CreateOptions) error {
 	dest, _ = filepath.Abs(dest)
-	for i, f := range filenames {
-		filenames[i] = filepath.Clean(f)
+	cleaned := make([]string, 0, len(filenames))
+	for _, f := range filenames {
+		cleaned = append(cleaned, path.Clean(filepath.ToSlash(f)))
+	}
+	filenames = cleaned
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if metadata == nil {
+		metadata = map[string]*CrawledFileType{}
 	}
 
 	root := &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{}}
-	files := make([]struct {
-		filename string
-		unpack   bool
-	}, 0)
-	links := make([]struct {
-		filename string
-		unpack   bool
-	}, 0)
-	unpackDirs := make([]string, 0)
-	var offset int64 = 0
+	files := make([]packEntry, 0)
+	links := make([]packEntry, 0)
+	rules, err := newUnpackRules(options)
+	if err != nil {
+		return err
+	}
+	compress, err := compileBasePattern(options.Compress)
+	if err != nil {
+		return err
+	}
+	compression := options.Compression
+	if compression == "" {
+		compression = CompressionGzip
+	}
+	if options.Compress != "" && !supportedCompression(compression) {
+		return unsupportedCompression(compression)
+	}
+	spool := &transformSpool{}
+	defer spool.close()
+	spools := &spoolPool{}
+	defer spools.close()
 
 	filenamesSorted := filenames
 	if options.Ordering != "" {
-		bs, err := os.ReadFile(options.Ordering)
-		if err == nil {
-			lines := strings.Split(string(bs), "\n")
-			ordering := make([]string, 0)
-			for _, line := range lines {
-				if i := strings.Index(line, ":"); i >= 0 {
-					line = strings.TrimSpace(line[i+1:])
-				} else {
-					line = strings.TrimSpace(line)
-				}
-				line = strings.TrimPrefix(line, "/")
-				comps := strings.Split(line, string(os.PathSeparator))
-				cur := src
-				for _, c := range comps {
-					cur = filepath.Join(cur, c)
-					ordering = append(ordering, cur)
-				}
-			}
-			filenamesSorted = make([]string, 0, len(filenames))
-			missing := 0
-			total := len(filenames)
-			for _, f := range ordering {
-				if !contains(filenamesSorted, f) && contains(filenames, f) {
-					filenamesSorted = append(filenamesSorted, f)
-				}
-			}
-			for _, f := range filenames {
-				if !contains(filenamesSorted, f) {
-					filenamesSorted = append(filenamesSorted, f)
-					missing++
-				}
-			}
-			_ = total
-			_ = missing
+		if ordering, err := readOrdering(options.Ordering); err == nil {
+			filenamesSorted = applyOrdering(filenames, ordering)
 		}
 	}
 
-	shouldUnpackPath := func(relativePath, unpack, unpackDir string) bool {
-		su := false
-		if unpack != "" {
-			su = matchBase(relativePath, unpack)
-		}
-		if !su && unpackDir != "" {
-			su = isUnpackedDir(relativePath, unpackDir, &unpackDirs)
-		}
-		return su
-	}
-
 	handleFile := func(filename string) error {
 		m, ok := metadata[filename]
 		if !ok {
-			typ, err := DetermineFileType(filename)
+			typ, err := DetermineFileTypeFS(fsys, filename)
 			if err != nil {
 				return err
 			}
 			metadata[filename] = typ
 			m = typ
 		}
-		relAll := relPath(src, filename)
-		if !options.Dot {
-			if strings.HasPrefix(filepath.Base(relAll), ".") {
-				if m.Type == "directory" {
-					return nil
-				}
-				return nil
-			}
+		if !options.Dot && strings.HasPrefix(path.Base(filename), ".") {
+			return nil
 		}
 		switch m.Type {
 		case "directory":
-			su := shouldUnpackPath(relAll, "", options.UnpackDir)
-			ensureDir(root, relAll, su)
+			ensureDir(root, filename, rules.dir(filename))
 		case "file":
-			su := shouldUnpackPath(relPath(src, filepath.Dir(filename)), options.Unpack, options.UnpackDir)
-			files = append(files, struct {
-				filename string
-				unpack   bool
-			}{filename, su})
-			// 创建文件节点并填充元数据
-			rel := relAll
-			dir := ensureDir(root, relPath(src, filepath.Dir(filename)), false)
-			name := filepath.Base(rel)
+			su := rules.file(filename)
+			// 创建文件节点并填充元数据；完整性信息先以等长占位写入，在写出数据时计算
+			dir := ensureDir(root, path.Dir(filename), false)
 			fe := &FilesystemFileEntry{Unpacked: su, Size: int(m.Stat.Size())}
-			f, err := os.Open(filename)
-			if err != nil {
-				return err
+			entry := packEntry{filename: filename, unpack: su, entry: fe}
+			var tr io.ReadCloser
+			if options.Transform != nil {
+				tr = options.Transform(sourcePath(fsys, filename))
+			}
+			if tr != nil {
+				// Transform 按遍历顺序串行调用，其输出先暂存
+				sec, err := spool.add(tr)
+				if err != nil {
+					return err
+				}
+				entry.content = sec
+				fe.Size = int(sec.Size())
 			}
-			integ, err := GetFileIntegrity(f)
-			f.Close()
+			entry.compress = !su && compress != nil && compress.Match(filename)
+			if !entry.compress {
+				fe.Integrity = placeholderIntegrity(int64(fe.Size))
+			}
+			if executableMode(m.Stat.Mode()) {
+				fe.Executable = true
+			}
+			files = append(files, entry)
+			dir[path.Base(filename)] = fe
+		case "link":
+			su := rules.link(filename)
+			target, err := readLinkFS(fsys, filename)
 			if err != nil {
 				return err
 			}
-			fe.Integrity = integ
-			if !isWindows() && (m.Stat.Mode()&0o100) != 0 {
-				fe.Executable = true
+			// 插入链接，链接目标记录为相对于打包根目录的路径
+			var linkTarget string
+			if d, ok := fsys.(dirFS); ok {
+				linkTarget = d.archiveLink(filename, target)
+			} else {
+				// 绝对路径须在拼接前拒绝，否则会被改写为归档内的相对路径
+				if path.IsAbs(target) {
+					return &PathError{Op: "pack", Path: filename, Err: linkEscapes(target)}
+				}
+				linkTarget = path.Join(path.Dir(filename), target)
 			}
-			if !su {
-				fe.Offset = strconv.FormatInt(offset, 10)
-				offset += int64(fe.Size)
+			if hasParentOutOf(linkTarget) || path.IsAbs(linkTarget) {
+				return &PathError{Op: "pack", Path: filename, Err: linkEscapes(linkTarget)}
 			}
-			dir[name] = fe
-		case "link":
-			su := shouldUnpackPath(relPath(src, filename), options.Unpack, options.UnpackDir)
-			links = append(links, struct {
-				filename string
-				unpack   bool
-			}{filename, su})
-			// 插入链接
-			rel := relPath(src, filename)
-			dir := ensureDir(root, relPath(src, filepath.Dir(filename)), false)
-			linkTarget := relPath(mustRealpath(src), filepath.Join(mustRealpath(filepath.Dir(filename)), mustReadlink(filename)))
-			le := &FilesystemLinkEntry{Link: linkTarget, EntryMetadata: EntryMetadata{Unpacked: su}}
-			dir[filepath.Base(rel)] = le
+			links = append(links, packEntry{filename: filename, unpack: su, link: target})
+			dir := ensureDir(root, path.Dir(filename), false)
+			dir[path.Base(filename)] = &FilesystemLinkEntry{Link: linkTarget, EntryMetadata: EntryMetadata{Unpacked: su}}
 		}
 		return nil
 	}
@@ -197,48 +281,417 @@ func CreatePackageFromFiles(src, dest string, filenames []string, metadata map[s
 	}
 	// 先构建头与偏移
 	for _, name := range filenamesSorted {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if err := handleFile(name); err != nil {
 			return err
 		}
 	}
-	// 写入 header
-	tmpFS := &Filesystem{}
-	tmpFS.SetHeader(root, 0)
-	out, err := createFilesystemWriteStream(tmpFS, dest)
+	// 压缩后的大小须在写出头之前确定，因此先由 worker 并发压缩并暂存，同时按原始内容计算完整性
+	if err := spools.compressFiles(ctx, fsys, files, compression, options.Concurrency); err != nil {
+		return err
+	}
+	// 按遍历顺序分配偏移
+	var offset int64
+	for i := range files {
+		if f := &files[i]; !f.unpack {
+			f.offset = offset
+			f.entry.Offset = strconv.FormatInt(offset, 10)
+			offset += int64(f.entry.Size)
+		}
+	}
+	withUnpacked := false
+	for _, f := range files {
+		withUnpacked = withUnpacked || f.unpack
+	}
+	for _, l := range links {
+		withUnpacked = withUnpacked || l.unpack
+	}
+	// 归档与 .unpacked 先写入临时位置，全部成功后再重命名到位
+	output, err := newAtomicOutput(dest, withUnpacked)
+	if err != nil {
+		return err
+	}
+	defer output.cleanup()
+	out := output.file
+	// 写入占位 header：文件大小在遍历时已确定，占位完整性信息与真实值长度相同，因此头长度固定
+	if err := writeFilesystemHeader(out, root); err != nil {
+		return err
+	}
+	headerLen, err := out.Seek(0, io.SeekCurrent)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
 
-	for _, f := range files {
-		if f.unpack {
-			filename := relPath(src, f.filename)
-			if err := CopyFile(dest+".unpacked", src, filename); err != nil {
-				return err
-			}
+	// 单次读取来源：写出数据（或 .unpacked 副本）的同时计算完整性信息。
+	// 各文件在数据区中的位置已固定，因此可由多个 worker 并发按位置写入
+	packFile := func(f packEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var in io.Reader
+		if f.content != nil {
+			in = io.NewSectionReader(f.content, 0, f.content.Size())
 		} else {
-			in, err := os.Open(f.filename)
+			src, err := fsys.Open(f.filename)
 			if err != nil {
 				return err
 			}
-			if _, err := io.Copy(out, in); err != nil {
-				in.Close()
+			defer src.Close()
+			in = src
+		}
+		var w io.Writer
+		if f.unpack {
+			unpacked, err := createUnpacked(f.filename, output.unpacked, metadata[f.filename].Stat.Mode())
+			if err != nil {
 				return err
 			}
-			in.Close()
+			defer unpacked.Close()
+			w = unpacked
+		} else {
+			w = io.NewOffsetWriter(out, headerLen+f.offset)
+		}
+		// 只读取声明的大小再检查剩余内容，以发现打包期间发生变化的文件并避免越界写入相邻文件的区域
+		iw := NewIntegrityWriter()
+		n, err := copyBuffer(io.MultiWriter(w, iw), io.LimitReader(contextReader{ctx, in}, int64(f.entry.Size)))
+		if err != nil {
+			return err
+		}
+		if n != int64(f.entry.Size) || !atEOF(in) {
+			return errors.New(f.filename + ": file size changed while packing")
+		}
+		if f.entry.Compression == nil {
+			f.entry.Integrity = iw.Integrity()
+		}
+		if f.unpack {
+			return w.(*os.File).Close()
 		}
+		return nil
+	}
+	if err := runWorkers(len(files), options.Concurrency, func(i int) error { return packFile(files[i]) }); err != nil {
+		return err
+	}
+
+	// 回写包含真实完整性信息的 header
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	cw := &countWriter{w: out}
+	if err := writeFilesystemHeader(cw, root); err != nil {
+		return err
 	}
+	if cw.n != headerLen {
+		return errors.New("asar: header size changed while packing")
+	}
+
 	for _, l := range links {
 		if l.unpack {
-			filename := relPath(src, l.filename)
-			if err := createSymlink(dest, filename, mustReadlink(l.filename)); err != nil {
+			if err := createSymlink(output.unpacked, filepath.FromSlash(l.filename), l.link); err != nil {
+				return err
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return output.commit()
+}
+
+// packEntry 打包时待写出的文件或链接
+type packEntry struct {
+	filename string
+	unpack   bool
+	link     string
+	content  *io.SectionReader // Transform 或压缩的输出，nil 表示直接读取来源文件
+	compress bool              // 写出头之前压缩并暂存
+	entry    *FilesystemFileEntry
+	offset   int64 // 在数据区中的偏移，仅对未解包的文件有效
+}
+
+// spoolPool 为并发压缩的各个 worker 提供独立的暂存文件
+type spoolPool struct {
+	mu   sync.Mutex
+	free []*transformSpool
+	all  []*transformSpool
+}
+
+func (p *spoolPool) get() *transformSpool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n := len(p.free); n > 0 {
+		s := p.free[n-1]
+		p.free = p.free[:n-1]
+		return s
+	}
+	s := &transformSpool{}
+	p.all = append(p.all, s)
+	return s
+}
+
+func (p *spoolPool) put(s *transformSpool) {
+	p.mu.Lock()
+	p.free = append(p.free, s)
+	p.mu.Unlock()
+}
+
+func (p *spoolPool) close() {
+	for _, s := range p.all {
+		s.close()
+	}
+}
+
+// compressFiles 以 concurrency 个 worker 压缩 files 中标记为 compress 的文件并暂存，
+// 填写其存储大小、原始内容的完整性信息与压缩信息
+func (p *spoolPool) compressFiles(ctx context.Context, fsys fs.FS, files []packEntry, algorithm string, concurrency int) error {
+	var idx []int
+	for i, f := range files {
+		if f.compress {
+			idx = append(idx, i)
+		}
+	}
+	return runWorkers(len(idx), concurrency, func(i int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		f := &files[idx[i]]
+		var r io.ReadCloser
+		if f.content != nil {
+			r = io.NopCloser(io.NewSectionReader(f.content, 0, f.content.Size()))
+		} else {
+			src, err := fsys.Open(f.filename)
+			if err != nil {
 				return err
 			}
+			r = src
+		}
+		s := p.get()
+		defer p.put(s)
+		sec, integ, n, err := s.compress(r, algorithm)
+		if err != nil {
+			return err
+		}
+		f.content = sec
+		f.entry.Size = int(sec.Size())
+		f.entry.Integrity = integ
+		f.entry.Compression = &FileCompression{Algorithm: algorithm, Size: int(n)}
+		return nil
+	})
+}
+
+// readOrdering 读取 ordering 文件，返回按顺序展开的 slash 相对路径（含各级父目录）
+func readOrdering(orderingPath string) ([]string, error) {
+	bs, err := os.ReadFile(orderingPath)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(bs), "\n")
+	ordering := make([]string, 0)
+	for _, line := range lines {
+		if i := strings.Index(line, ":"); i >= 0 {
+			line = strings.TrimSpace(line[i+1:])
+		} else {
+			line = strings.TrimSpace(line)
+		}
+		line = strings.TrimPrefix(line, "/")
+		comps := strings.Split(line, string(os.PathSeparator))
+		cur := ""
+		for _, c := range comps {
+			cur = path.Join(cur, c)
+			ordering = append(ordering, cur)
 		}
 	}
+	return ordering, nil
+}
+
+// applyOrdering 先按 ordering 排列已存在的文件，其余文件保持原顺序追加在后
+func applyOrdering(filenames, ordering []string) []string {
+	exists := make(map[string]bool, len(filenames))
+	for _, f := range filenames {
+		exists[f] = true
+	}
+	seen := make(map[string]bool, len(filenames))
+	sorted := make([]string, 0, len(filenames))
+	for _, f := range ordering {
+		if exists[f] && !seen[f] {
+			seen[f] = true
+			sorted = append(sorted, f)
+		}
+	}
+	for _, f := range filenames {
+		if !seen[f] {
+			seen[f] = true
+			sorted = append(sorted, f)
+		}
+	}
+	return sorted
+}
+
+// createUnpacked 在 destUnpacked 下创建 filename 对应的文件，并保持权限
+func createUnpacked(filename, destUnpacked string, mode fs.FileMode) (*os.File, error) {
+	targetFile := filepath.Join(destUnpacked, filepath.FromSlash(filename))
+	if err := os.MkdirAll(filepath.Dir(targetFile), 0o755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(targetFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+}
+
+// placeholderIntegrity 返回与大小为 size 的文件真实完整性信息序列化长度相同的占位值
+func placeholderIntegrity(size int64) FileIntegrity {
+	zero := strings.Repeat("0", 2*sha256.Size)
+	blocks := make([]string, (size+BLOCK_SIZE-1)/BLOCK_SIZE)
+	for i := range blocks {
+		blocks[i] = zero
+	}
+	return FileIntegrity{Algorithm: ALGORITHM, Hash: zero, BlockSize: BLOCK_SIZE, Blocks: blocks}
+}
+
+// runWorkers 以 concurrency 个 goroutine 依次处理 0..n-1，返回第一个错误；出错后不再分派新任务
+func runWorkers(n, concurrency int, fn func(i int) error) error {
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		next     int
+	)
+	take := func() (int, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr != nil || next >= n {
+			return 0, false
+		}
+		next++
+		return next - 1, true
+	}
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i, ok := take()
+				if !ok {
+					return
+				}
+				if err := fn(i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// contextReader 在 ctx 取消后使读取返回错误，使大文件的复制也能及时中止
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// atEOF 判断 r 是否已读完
+func atEOF(r io.Reader) bool {
+	var b [1]byte
+	n, _ := io.ReadFull(r, b[:])
+	return n == 0
+}
+
+// countWriter 统计写入的字节数
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// sourcePath 返回传给 Transform 的文件路径：磁盘目录为绝对路径，其他来源为 slash 相对路径
+func sourcePath(fsys fs.FS, name string) string {
+	if d, ok := fsys.(dirFS); ok {
+		return d.join(name)
+	}
+	return name
+}
+
+// transformSpool 将 Transform 的输出暂存到同一个临时文件中，供计算头信息与写出数据复用
+type transformSpool struct {
+	f      *os.File
+	offset int64
+}
+
+// add 读取并关闭 r，返回暂存内容对应的区段
+func (s *transformSpool) add(r io.ReadCloser) (*io.SectionReader, error) {
+	defer r.Close()
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	n, err := copyBuffer(io.NewOffsetWriter(s.f, s.offset), r)
+	if err != nil {
+		return nil, err
+	}
+	sec := io.NewSectionReader(s.f, s.offset, n)
+	s.offset += n
+	return sec, nil
+}
+
+// addWithIntegrity 与 add 相同，并在暂存的同时计算内容的完整性信息
+func (s *transformSpool) addWithIntegrity(r io.ReadCloser) (*io.SectionReader, FileIntegrity, error) {
+	defer r.Close()
+	if err := s.open(); err != nil {
+		return nil, FileIntegrity{}, err
+	}
+	iw := NewIntegrityWriter()
+	n, err := copyBuffer(io.MultiWriter(io.NewOffsetWriter(s.f, s.offset), iw), r)
+	if err != nil {
+		return nil, FileIntegrity{}, err
+	}
+	sec := io.NewSectionReader(s.f, s.offset, n)
+	s.offset += n
+	return sec, iw.Integrity(), nil
+}
+
+// open 在首次使用时创建暂存文件
+func (s *transformSpool) open() error {
+	if s.f != nil {
+		return nil
+	}
+	f, err := os.CreateTemp("", "asar-transform-*")
+	if err != nil {
+		return err
+	}
+	s.f = f
 	return nil
 }
 
+func (s *transformSpool) close() {
+	if s.f == nil {
+		return
+	}
+	s.f.Close()
+	os.Remove(s.f.Name())
+	s.f = nil
+}
+
 // StatFile 获取单个条目信息
 func StatFile(archivePath, filename string, followLinks bool) (FilesystemEntry, error) {
 	fsys, err := ReadFilesystemSync(archivePath)
@@ -268,81 +721,115 @@ func ExtractFile(archivePath, filename string, followLinks bool) ([]byte, error)
 	if err != nil {
 		return nil, err
 	}
-	fi, err := fsys.GetFile(filename, followLinks)
+	// 按解析链接后的真实路径读取，unpacked 内容不会经由磁盘上的链接越出 .unpacked 目录
+	fi, real, err := fsys.findNode(filename, followLinks)
 	if err != nil {
-		return nil, err
+		return nil, &PathError{Op: "extract", Archive: archivePath, Path: filename, Err: err}
 	}
 	f, ok := fi.(*FilesystemFileEntry)
 	if !ok {
-		return nil, errors.New("not a file: " + filename)
+		return nil, &PathError{Op: "extract", Archive: archivePath, Path: filename, Err: ErrNotFile}
 	}
-	return ReadFileSync(fsys, filename, f)
+	content, err := ReadFileSync(fsys, real, f)
+	if err != nil {
+		return nil, pathError("extract", archivePath, filename, err)
+	}
+	return content, nil
 }
 
-// ExtractAll 提取全部文件到目标目录
+// ExtractAll 提取全部文件到目标目录，头中的不合法条目会被跳过
 func ExtractAll(archivePath, dest string) error {
-	fsys, err := ReadFilesystemSync(archivePath)
+	return ExtractAllWithOptions(archivePath, dest, ReadOptions{})
+}
+
+// ExtractAllWithOptions 按选项读取头（不经过缓存）并提取全部文件；
+// Strict 为 true 时头中存在不合法条目即不提取任何内容，超出限制时返回 *LimitError
+func ExtractAllWithOptions(archivePath, dest string, options ReadOptions) error {
+	fsys, err := loadFilesystem(archivePath, options)
 	if err != nil {
 		return err
 	}
 	filenames := fsys.ListFiles(false)
 	followLinks := os.PathSeparator == '\\' // Windows 提取为普通文件
 	if err := os.MkdirAll(dest, 0o755); err != nil {
-		return err
+		return &PathError{Op: "extract", Archive: archivePath, Err: err}
 	}
 	for _, full := range filenames {
 		filename := strings.TrimPrefix(full, "/")
-		destFilename := filepath.Join(dest, filename)
-		if isOutOf(dest, destFilename) {
-			return errors.New(full + ": file \"" + destFilename + "\" writes out of the package")
-		}
-		fileEntry, _ := fsys.GetFile(filename, followLinks)
-		if dir, ok := fileEntry.(*FilesystemDirectoryEntry); ok {
-			_ = dir
-			if err := os.MkdirAll(destFilename, 0o755); err != nil {
-				return err
-			}
-		} else if lnk, ok := fileEntry.(*FilesystemLinkEntry); ok {
-			linkSrc := filepath.Dir(filepath.Join(dest, lnk.Link))
-			linkDest := filepath.Dir(destFilename)
-			rel := relPath(linkDest, linkSrc)
-			_ = os.Remove(destFilename)
-			linkTo := filepath.Join(rel, filepath.Base(lnk.Link))
-			if isOutOf(dest, linkSrc) {
-				return errors.New(full + ": file \"" + lnk.Link + "\" links out of the package to \"" + linkSrc + "\"")
-			}
-			if err := os.Symlink(linkTo, destFilename); err != nil {
-				return err
-			}
-		} else if f, ok := fileEntry.(*FilesystemFileEntry); ok {
-			content, err := ReadFileSync(fsys, filename, f)
-			if err != nil {
-				return err
-			}
-			if err := os.MkdirAll(filepath.Dir(destFilename), 0o755); err != nil {
-				return err
-			}
-			if err := os.WriteFile(destFilename, content, 0o644); err != nil {
-				return err
-			}
-			if f.Executable {
-				_ = os.Chmod(destFilename, 0o755)
-			}
+		if err := extractEntry(fsys, dest, filename, followLinks); err != nil {
+			return pathError("extract", archivePath, filename, err)
 		}
 	}
 	return nil
 }
 
-// ------------- 辅助函数 -------------
+// extractEntry 将单个条目写出到 dest 下，任何目标都不会越出 dest
+func extractEntry(fsys *Filesystem, dest, filename string, followLinks bool) error {
+	destFilename := filepath.Join(dest, filename)
+	if isOutOf(dest, destFilename) {
+		return linkEscapes(destFilename)
+	}
+	fileEntry, err := fsys.GetFile(filename, followLinks)
+	if err != nil {
+		return err
+	}
+	switch e := fileEntry.(type) {
+	case *FilesystemDirectoryEntry:
+		return os.MkdirAll(destFilename, 0o755)
+	case *FilesystemLinkEntry:
+		linkSrc := filepath.Dir(filepath.Join(dest, e.Link))
+		if isOutOf(dest, linkSrc) {
+			return linkEscapes(e.Link)
+		}
+		rel := relPath(filepath.Dir(destFilename), linkSrc)
+		_ = os.Remove(destFilename)
+		return os.Symlink(filepath.Join(rel, filepath.Base(e.Link)), destFilename)
+	case *FilesystemFileEntry:
+		if err := os.MkdirAll(filepath.Dir(destFilename), 0o755); err != nil {
+			return err
+		}
+		return extractFileTo(fsys, filename, e, destFilename)
+	}
+	return nil
+}
 
-func matchBase(pathRel, pattern string) bool {
-	// 简化实现：仅匹配 basename
-	return matchGlob(filepath.Base(pathRel), pattern)
+// extractFileTo 以流的方式将文件条目写出到 destFilename（压缩存储的文件边读边解压），内存占用与文件大小无关
+func extractFileTo(fsys *Filesystem, filename string, f *FilesystemFileEntry, destFilename string) error {
+	in, err := openContent(fsys, filename, f)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	var perm os.FileMode = 0o644
+	if f.Executable {
+		perm = 0o755
+	}
+	out, err := os.OpenFile(destFilename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := copyBuffer(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if f.Executable {
+		_ = os.Chmod(destFilename, 0o755)
+	}
+	return nil
 }
 
-func matchGlob(name, pattern string) bool {
-	ok, _ := filepath.Match(pattern, name)
-	return ok
+// ------------- 辅助函数 -------------
+
+// compileBasePattern 以 minimatch 的 matchBase 语义编译模式：不含 '/' 的模式只与 basename 比较；
+// pattern 为空时返回 nil
+func compileBasePattern(pattern string) (*Glob, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return ParseGlob(pattern, GlobOptions{MatchBase: true})
 }
 
 func contains[T comparable](arr []T, v T) bool {
@@ -0,0 +1,294 @@
+package asar
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// writeTree 在 dir 下按 slash 路径创建文件；以 ".sh" 结尾的文件带可执行位
+func writeTree(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		mode := fs.FileMode(0o644)
+		if strings.HasSuffix(name, ".sh") {
+			mode = 0o755
+		}
+		if err := os.WriteFile(p, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testTree 创建包含普通文件、可执行文件、隐藏文件、可压缩文件与符号链接的来源目录
+func testTree(t testing.TB) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.txt":          "hello",
+		".hidden":        "hidden",
+		"dir/b.txt":      "world",
+		"dir/run.sh":     "#!/bin/sh\necho hi\n",
+		"dir/sub/c.js":   strings.Repeat("function f() { return 42; }\n", 200),
+		"assets/img.png": string(bytes.Repeat([]byte{0x89, 'P', 'N', 'G'}, 1024)),
+	})
+	if err := os.Symlink("dir/b.txt", filepath.Join(dir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir", filepath.Join(dir, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// packTree 以 options 打包 src，返回归档路径
+func packTree(t testing.TB, src string, options CreateOptions) string {
+	t.Helper()
+	dest := filepath.Join(t.TempDir(), "app.asar")
+	options.Dot = true
+	if err := CreatePackageWithOptions(src, dest, options); err != nil {
+		t.Fatal(err)
+	}
+	return dest
+}
+
+// archiveFiles 返回归档中全部路径（不含开头的 '/'）
+func archiveFiles(t testing.TB, archive string) []string {
+	t.Helper()
+	fsys, err := loadFilesystem(archive, ReadOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	walkEntries(fsys.header, func(p string, _ FilesystemEntry) error {
+		names = append(names, p)
+		return nil
+	})
+	return names
+}
+
+func TestCreatePackageFromFSRejectsAbsoluteLinks(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"a.txt": "a"})
+	if err := os.Symlink("/etc/passwd", filepath.Join(src, "abs")); err != nil {
+		t.Fatal(err)
+	}
+	fsys := os.DirFS(src)
+	if _, ok := fsys.(ReadLinkFS); !ok {
+		t.Skip("os.DirFS does not implement ReadLinkFS")
+	}
+	err := CreatePackageFromFS(fsys, filepath.Join(t.TempDir(), "app.asar"), CreateOptions{})
+	if !errors.Is(err, ErrLinkEscapes) {
+		t.Fatalf("err = %v, want ErrLinkEscapes", err)
+	}
+}
+
+func TestCreatePackageFromZipLinks(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("a.txt")
+	w.Write([]byte("hello"))
+	h := &zip.FileHeader{Name: "link"}
+	h.SetMode(fs.ModeSymlink | 0o777)
+	w, _ = zw.CreateHeader(h)
+	w.Write([]byte("a.txt"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(t.TempDir(), "app.asar")
+	if err := CreatePackageFromFS(zr, dest, CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// zip.Reader 不支持读取链接目标，链接按普通文件打包其内容
+	got, err := ExtractFile(dest, "link", false)
+	if err != nil || string(got) != "a.txt" {
+		t.Fatalf("link = %q, %v", got, err)
+	}
+}
+
+// compareTree 比较解压结果与来源目录：文件内容、可执行位与符号链接目标
+func compareTree(t testing.TB, want, got string) {
+	t.Helper()
+	err := filepath.WalkDir(want, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(want, p)
+		q := filepath.Join(got, rel)
+		wi, _ := os.Lstat(p)
+		gi, err := os.Lstat(q)
+		if err != nil {
+			t.Errorf("%s: %v", rel, err)
+			return nil
+		}
+		switch {
+		case wi.Mode()&fs.ModeSymlink != 0:
+			wl, _ := os.Readlink(p)
+			gl, _ := os.Readlink(q)
+			if gi.Mode()&fs.ModeSymlink == 0 || filepath.Clean(wl) != filepath.Clean(gl) {
+				t.Errorf("%s: link %q, want %q", rel, gl, wl)
+			}
+		case wi.Mode().IsRegular():
+			wb, _ := os.ReadFile(p)
+			gb, _ := os.ReadFile(q)
+			if !bytes.Equal(wb, gb) {
+				t.Errorf("%s: content differs", rel)
+			}
+			if wi.Mode()&0o100 != gi.Mode()&0o100 {
+				t.Errorf("%s: mode %v, want %v", rel, gi.Mode(), wi.Mode())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractAllRoundTrip(t *testing.T) {
+	src := testTree(t)
+	for _, options := range []CreateOptions{{}, {UnpackDir: "assets", Unpack: "*.sh"}, {Compress: "*.{js,txt}"}} {
+		archive := packTree(t, src, options)
+		dest := t.TempDir()
+		if err := ExtractAll(archive, dest); err != nil {
+			t.Fatal(err)
+		}
+		compareTree(t, src, dest)
+	}
+}
+
+func TestExtractAllStreams(t *testing.T) {
+	src := t.TempDir()
+	const size = 32 << 20
+	writeTree(t, src, map[string]string{"big.bin": string(bytes.Repeat([]byte("0123456789abcdef"), size/16))})
+	archive := packTree(t, src, CreateOptions{})
+	dest := t.TempDir()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := ExtractAll(archive, dest); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	// 解压大文件时分配量应与文件大小无关
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > size/4 {
+		t.Fatalf("extracting a %d byte file allocated %d bytes", size, alloc)
+	}
+	compareTree(t, src, dest)
+}
+
+func TestTransform(t *testing.T) {
+	src := testTree(t)
+	archive := packTree(t, src, CreateOptions{Unpack: "b.txt", Transform: func(p string) io.ReadCloser {
+		if !strings.HasSuffix(p, ".txt") {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+		return io.NopCloser(strings.NewReader(strings.ToUpper(string(data)) + "!"))
+	}})
+	fsys, err := loadFilesystem(archive, ReadOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"a.txt": "HELLO!", "dir/b.txt": "WORLD!", "dir/run.sh": "#!/bin/sh\necho hi\n"} {
+		e, _ := fsys.GetFile(name, false)
+		f := e.(*FilesystemFileEntry)
+		got, err := ReadFileSync(fsys, name, f)
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", name, got, err, want)
+		}
+		integ, _ := GetFileIntegrity(strings.NewReader(want))
+		if f.Size != len(want) || f.Integrity.Hash != integ.Hash {
+			t.Errorf("%s: header describes untransformed content", name)
+		}
+	}
+}
+
+// countingFS 统计经由 Open 打开的文件读取的字节数
+type countingFS struct {
+	dirFS
+	n atomic.Int64
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	f, err := c.dirFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &countingFile{File: f.(*os.File), n: &c.n}, nil
+}
+
+type countingFile struct {
+	*os.File
+	n *atomic.Int64
+}
+
+func (f *countingFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.n.Add(int64(n))
+	return n, err
+}
+
+// TestPackReadsEachFileOnce 打包时每个文件只读取一次：读取的字节数等于文件总大小
+func TestPackReadsEachFileOnce(t *testing.T) {
+	src := testTree(t)
+	var total int64
+	filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if info, _ := os.Lstat(p); info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	for _, options := range []CreateOptions{{Dot: true}, {Dot: true, UnpackDir: "assets"}} {
+		cfs := &countingFS{dirFS: dirFS(src)}
+		if err := CreatePackageFromFS(cfs, filepath.Join(t.TempDir(), "app.asar"), options); err != nil {
+			t.Fatal(err)
+		}
+		if got := cfs.n.Load(); got != total {
+			t.Errorf("%+v: read %d bytes, want %d", options, got, total)
+		}
+	}
+}
+
+// TestPackConcurrencyDeterministic 不同并发度打包的归档逐字节相同
+func TestPackConcurrencyDeterministic(t *testing.T) {
+	src := testTree(t)
+	for i := 0; i < 50; i++ {
+		writeTree(t, src, map[string]string{fmt.Sprintf("many/%02d.txt", i): strings.Repeat("x", i*97)})
+	}
+	for _, options := range []CreateOptions{{}, {UnpackDir: "many", Compress: "*.js"}, {Compress: "*.{js,txt}"}} {
+		var want []byte
+		for _, n := range []int{1, 2, 4, 16} {
+			options.Concurrency = n
+			got, err := os.ReadFile(packTree(t, src, options))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want == nil {
+				want = got
+			} else if !bytes.Equal(got, want) {
+				t.Fatalf("%+v: output differs from Concurrency 1", options)
+			}
+		}
+	}
+}
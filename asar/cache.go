@@ -0,0 +1,154 @@
+package asar
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Cache 归档头缓存，可被多个 goroutine 并发使用。
+// 以解析符号链接后的绝对路径为键，经不同路径（如符号链接）访问同一归档时共用解析结果，
+// 但各路径得到的 Filesystem 仍以调用方给出的路径为根，.unpacked 在该路径旁查找。
+// 归档在磁盘上发生变化（大小、修改时间或 inode）时会自动重新加载
+type Cache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type cacheEntry struct {
+	key  string
+	fsys *Filesystem
+	stat os.FileInfo
+	// views 为经其他路径访问时的 Filesystem，与 fsys 共用头，以访问路径为键
+	views map[string]*Filesystem
+}
+
+// view 返回以 abs 为根路径的 Filesystem，调用方须持有锁
+func (ce *cacheEntry) view(abs string) *Filesystem {
+	if ce.fsys.src == abs {
+		return ce.fsys
+	}
+	if v, ok := ce.views[abs]; ok {
+		return v
+	}
+	v := *ce.fsys
+	v.src = abs
+	if ce.views == nil {
+		ce.views = map[string]*Filesystem{}
+	}
+	ce.views[abs] = &v
+	return &v
+}
+
+// defaultCache 为 ReadFilesystemSync 等包级函数使用的全局缓存
+var defaultCache = NewCache(0)
+
+// NewCache 创建独立的缓存实例；maxEntries 大于 0 时按最近最少使用（LRU）淘汰，0 表示不限制
+func NewCache(maxEntries int) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+	}
+}
+
+// ReadFilesystem 读取归档头，命中且归档未变化时直接返回缓存
+func (c *Cache) ReadFilesystem(archivePath string) (*Filesystem, error) {
+	abs, err := filepath.Abs(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	key := canonicalArchivePath(abs)
+	st, err := os.Stat(key)
+	if err != nil {
+		c.Uncache(key)
+		return nil, err
+	}
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		ce := el.Value.(*cacheEntry)
+		if sameArchiveFile(ce.stat, st) {
+			c.lru.MoveToFront(el)
+			fsys := ce.view(abs)
+			c.mu.Unlock()
+			return fsys, nil
+		}
+		c.removeElement(el)
+	}
+	c.mu.Unlock()
+
+	// 在锁外解析头，避免大归档阻塞其他读取
+	fsys, err := loadFilesystem(abs, ReadOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		ce := el.Value.(*cacheEntry)
+		if sameArchiveFile(ce.stat, st) {
+			c.lru.MoveToFront(el)
+			return ce.view(abs), nil
+		}
+		c.removeElement(el)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, fsys: fsys, stat: st})
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		c.removeElement(c.lru.Back())
+	}
+	return fsys, nil
+}
+
+// Uncache 移除指定归档的缓存，返回是否存在
+func (c *Cache) Uncache(archivePath string) bool {
+	key := canonicalArchivePath(archivePath)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if ok {
+		c.removeElement(el)
+	}
+	return ok
+}
+
+// UncacheAll 清空缓存
+func (c *Cache) UncacheAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+}
+
+// Len 返回当前缓存的归档数量
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *Cache) removeElement(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+// canonicalArchivePath 返回解析符号链接后的绝对路径，路径不存在时退化为绝对路径
+func canonicalArchivePath(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		abs = filepath.Clean(p)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
+}
+
+// sameArchiveFile 判断两次 stat 是否对应同一个未修改的文件
+func sameArchiveFile(a, b os.FileInfo) bool {
+	return a.Size() == b.Size() && a.ModTime().Equal(b.ModTime()) && os.SameFile(a, b)
+}
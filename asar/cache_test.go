@@ -0,0 +1,75 @@
+package asar
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestCacheSymlinkedArchiveKeepsUnpacked(t *testing.T) {
+	archive := packTree(t, testTree(t), CreateOptions{UnpackDir: "assets"})
+	// 归档本体移到别处，.unpacked 留在符号链接旁
+	store := filepath.Join(t.TempDir(), "store.asar")
+	if err := os.Rename(archive, store); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(store, archive); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCache(0)
+	fsys, err := c.ReadFilesystem(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsys.GetRootPath() != archive {
+		t.Fatalf("root path = %s, want %s", fsys.GetRootPath(), archive)
+	}
+	e, _ := fsys.GetFile("assets/img.png", true)
+	if _, err := ReadFileSync(fsys, "assets/img.png", e.(*FilesystemFileEntry)); err != nil {
+		t.Fatal(err)
+	}
+	// 经目标路径访问时共用解析结果，但以目标路径为根
+	direct, err := c.ReadFilesystem(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if direct.GetRootPath() != store || direct.header != fsys.header || c.Len() != 1 {
+		t.Fatalf("direct view: root %s, shared header %v, len %d", direct.GetRootPath(), direct.header == fsys.header, c.Len())
+	}
+	if again, _ := c.ReadFilesystem(archive); again != fsys {
+		t.Fatal("cache miss on unchanged archive")
+	}
+}
+
+func TestCacheReloadsChangedArchive(t *testing.T) {
+	src := testTree(t)
+	archive := packTree(t, src, CreateOptions{})
+	c := NewCache(0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.ReadFilesystem(archive); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	before, _ := c.ReadFilesystem(archive)
+	writeTree(t, src, map[string]string{"new.txt": "new"})
+	if err := CreatePackageWithOptions(src, archive, CreateOptions{Dot: true}); err != nil {
+		t.Fatal(err)
+	}
+	after, err := c.ReadFilesystem(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after == before {
+		t.Fatal("changed archive was not reloaded")
+	}
+	if _, err := after.GetFile("new.txt", false); err != nil {
+		t.Fatal(err)
+	}
+}
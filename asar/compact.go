@@ -0,0 +1,141 @@
+package asar
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// CompactOrder 压缩后数据区中文件的排列顺序
+type CompactOrder int
+
+const (
+	// CompactHeaderOrder 按头中的路径顺序（与打包时一致）
+	CompactHeaderOrder CompactOrder = iota
+	// CompactSizeOrder 按文件大小从小到大，大小相同时按路径顺序
+	CompactSizeOrder
+)
+
+// CompactOptions 压缩归档的选项
+type CompactOptions struct {
+	Order CompactOrder
+	// Ordering 非空时按 ordering 文件（格式与打包相同）排列，未列出的文件按 Order 追加在后
+	Ordering string
+}
+
+// CompactResult 压缩前后的归档大小（不含 .unpacked）
+type CompactResult struct {
+	OldSize   int64
+	NewSize   int64
+	Reclaimed int64 // OldSize - NewSize
+}
+
+// Compact 重写 src 的数据区，只保留头中引用的字节并按 options 指定的顺序排列，结果写入 dst；
+// dst 可以与 src 相同。多个条目引用同一段数据时只保留一份。写入过程与打包一样先写临时文件再重命名
+func Compact(src, dst string, options CompactOptions) (CompactResult, error) {
+	src, err := filepath.Abs(src)
+	if err != nil {
+		return CompactResult{}, err
+	}
+	dst, err = filepath.Abs(dst)
+	if err != nil {
+		return CompactResult{}, err
+	}
+	fsys, err := loadFilesystem(src, ReadOptions{Strict: true})
+	if err != nil {
+		return CompactResult{}, err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return CompactResult{}, err
+	}
+	defer in.Close()
+	st, err := in.Stat()
+	if err != nil {
+		return CompactResult{}, err
+	}
+	dataStart := int64(8 + fsys.GetHeaderSize())
+
+	var names []string
+	files := map[string]*FilesystemFileEntry{}
+	var unpacked []string
+	walkEntries(fsys.header, func(p string, entry FilesystemEntry) error {
+		switch t := entry.(type) {
+		case *FilesystemFileEntry:
+			if t.Unpacked {
+				unpacked = append(unpacked, p)
+			} else {
+				names = append(names, p)
+				files[p] = t
+			}
+		case *FilesystemLinkEntry:
+			if t.Unpacked {
+				unpacked = append(unpacked, p)
+			}
+		}
+		return nil
+	})
+	if options.Order == CompactSizeOrder {
+		sort.SliceStable(names, func(i, j int) bool { return files[names[i]].Size < files[names[j]].Size })
+	}
+	if options.Ordering != "" {
+		ordering, err := readOrdering(options.Ordering)
+		if err != nil {
+			return CompactResult{}, err
+		}
+		names = applyOrdering(names, ordering)
+	}
+
+	// 按新顺序分配偏移，引用同一段数据的条目共用新位置
+	type span struct{ offset, size int64 }
+	type copyRange struct{ from, size int64 }
+	relocated := map[span]int64{}
+	var ranges []copyRange
+	var offset int64
+	for _, name := range names {
+		f := files[name]
+		old := span{size: int64(f.Size)}
+		if f.Offset != "" {
+			old.offset, _ = strconv.ParseInt(f.Offset, 10, 64)
+		}
+		if off, ok := relocated[old]; ok {
+			f.Offset = strconv.FormatInt(off, 10)
+			continue
+		}
+		relocated[old] = offset
+		f.Offset = strconv.FormatInt(offset, 10)
+		ranges = append(ranges, copyRange{dataStart + old.offset, old.size})
+		offset += old.size
+	}
+
+	output, err := newAtomicOutput(dst, len(unpacked) > 0)
+	if err != nil {
+		return CompactResult{}, err
+	}
+	defer output.cleanup()
+	w := &countWriter{w: output.file}
+	if err := writeFilesystemHeader(w, fsys.header); err != nil {
+		return CompactResult{}, err
+	}
+	for _, r := range ranges {
+		n, err := copyBuffer(w, io.NewSectionReader(in, r.from, r.size))
+		if err != nil {
+			return CompactResult{}, err
+		}
+		if n != r.size {
+			return CompactResult{}, corruptHeader("file data is out of the archive bounds", io.ErrUnexpectedEOF)
+		}
+	}
+	for _, name := range unpacked {
+		entry, _, _ := fsys.findNode(name, false)
+		if err := copyUnpackedEntry(src, output.unpacked, name, entry, nil, 0); err != nil {
+			return CompactResult{}, &PathError{Op: "compact", Archive: src, Path: name, Err: err}
+		}
+	}
+	if err := output.commit(); err != nil {
+		return CompactResult{}, err
+	}
+	return CompactResult{OldSize: st.Size(), NewSize: w.n, Reclaimed: st.Size() - w.n}, nil
+}
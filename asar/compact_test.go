@@ -0,0 +1,60 @@
+package asar
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompactRoundTrip(t *testing.T) {
+	src := testTree(t)
+	archive := packTree(t, src, CreateOptions{UnpackDir: "assets", Compress: "*.js"})
+	// 替换文件后旧内容成为无用字节
+	e, err := Edit(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddFile("dir/sub/c.js", strings.NewReader("function f() { return 42; }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, src, map[string]string{"dir/sub/c.js": "function f() { return 42; }\n"})
+
+	for _, options := range []CompactOptions{{}, {Order: CompactSizeOrder}} {
+		dst := filepath.Join(t.TempDir(), "compact.asar")
+		res, err := Compact(archive, dst, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Reclaimed <= 0 || res.OldSize-res.NewSize != res.Reclaimed {
+			t.Errorf("%+v: result %+v", options, res)
+		}
+		dest := t.TempDir()
+		if err := ExtractAll(dst, dest); err != nil {
+			t.Fatal(err)
+		}
+		compareTree(t, src, dest)
+	}
+}
+
+func TestCompactTruncated(t *testing.T) {
+	archive := packTree(t, testTree(t), CreateOptions{})
+	st, err := os.Stat(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(archive, st.Size()-10); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "compact.asar")
+	if _, err := Compact(archive, dst, CompactOptions{}); !errors.Is(err, ErrCorruptHeader) {
+		t.Fatalf("err = %v, want ErrCorruptHeader", err)
+	}
+	if _, err := os.Stat(dst); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("output left behind: %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+package asar
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// 打包时可选的压缩算法（CreateOptions.Compression）
+const (
+	CompressionGzip    = "gzip"
+	CompressionDeflate = "deflate"
+)
+
+// supportedCompression 判断是否支持该压缩算法
+func supportedCompression(algorithm string) bool {
+	return algorithm == CompressionGzip || algorithm == CompressionDeflate
+}
+
+func unsupportedCompression(algorithm string) error {
+	return errors.New("unsupported compression algorithm " + strconv.Quote(algorithm))
+}
+
+// newCompressor 返回以 algorithm 压缩并写入 w 的 writer，Close 时写出剩余数据
+func newCompressor(algorithm string, w io.Writer) (io.WriteCloser, error) {
+	switch algorithm {
+	case CompressionGzip:
+		return gzip.NewWriterLevel(w, gzip.BestCompression)
+	case CompressionDeflate:
+		return flate.NewWriter(w, flate.BestCompression)
+	}
+	return nil, unsupportedCompression(algorithm)
+}
+
+// contentSize 返回文件原始内容的大小：压缩存储时为解压后的大小
+func contentSize(f *FilesystemFileEntry) int64 {
+	if f.Compression != nil {
+		return int64(f.Compression.Size)
+	}
+	return int64(f.Size)
+}
+
+// decompressReader 解压存储的数据，并检查解压后的大小与头中记录的一致
+type decompressReader struct {
+	zr   io.Reader
+	raw  io.Closer
+	left int64
+}
+
+// newDecompressReader 返回 raw 中压缩数据的解压流；raw 实现 io.Closer 时由返回的流（或出错时由本函数）关闭
+func newDecompressReader(raw io.Reader, c *FileCompression) (io.ReadCloser, error) {
+	closer, _ := raw.(io.Closer)
+	var zr io.Reader
+	switch c.Algorithm {
+	case CompressionGzip:
+		gr, err := gzip.NewReader(raw)
+		if err != nil {
+			if closer != nil {
+				closer.Close()
+			}
+			return nil, err
+		}
+		gr.Multistream(false)
+		zr = gr
+	case CompressionDeflate:
+		zr = flate.NewReader(raw)
+	default:
+		return nil, unsupportedCompression(c.Algorithm)
+	}
+	return &decompressReader{zr: zr, raw: closer, left: int64(c.Size)}, nil
+}
+
+func (r *decompressReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > r.left+1 {
+		p = p[:r.left+1]
+	}
+	n, err := r.zr.Read(p)
+	r.left -= int64(n)
+	switch {
+	case r.left < 0:
+		return n, corruptHeader("decompressed data is larger than the recorded size", nil)
+	case err == io.EOF && r.left > 0:
+		return n, corruptHeader("decompressed data is smaller than the recorded size", io.ErrUnexpectedEOF)
+	}
+	return n, err
+}
+
+func (r *decompressReader) Close() error {
+	if c, ok := r.zr.(io.Closer); ok {
+		c.Close()
+	}
+	if r.raw != nil {
+		return r.raw.Close()
+	}
+	return nil
+}
+
+// readCompressed 解压 raw 中的数据并完整读入内存
+func readCompressed(raw io.Reader, c *FileCompression) ([]byte, error) {
+	zr, err := newDecompressReader(raw, c)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(zr); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compressedFileReader 压缩存储文件的 FileReader：顺序读取（包括向前 Seek）时边读边解压，
+// 向后 Seek 或调用 ReadAt 时才将解压后的完整内容读入内存，之后的读取都从内存中进行
+type compressedFileReader struct {
+	raw    FileReader // 存储的压缩数据，Close 时关闭
+	stored int64
+	c      *FileCompression
+
+	mu   sync.Mutex
+	zr   io.ReadCloser // 当前的解压流，已读到 zpos
+	zpos int64
+	pos  int64
+	buf  []byte
+}
+
+// newCompressedFileReader 返回 raw 中 stored 字节压缩数据的 FileReader；出错时关闭 raw
+func newCompressedFileReader(raw FileReader, stored int64, c *FileCompression) (*compressedFileReader, error) {
+	zr, err := newDecompressReader(io.NewSectionReader(raw, 0, stored), c)
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return &compressedFileReader{raw: raw, stored: stored, c: c, zr: zr}, nil
+}
+
+func (r *compressedFileReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.buf == nil && r.pos != r.zpos {
+		switch {
+		case r.pos >= int64(r.c.Size):
+			return 0, io.EOF
+		case r.pos > r.zpos:
+			// 向前 Seek：丢弃中间的数据即可
+			n, err := io.CopyN(io.Discard, r.zr, r.pos-r.zpos)
+			r.zpos += n
+			if err != nil {
+				return 0, err
+			}
+		default:
+			if err := r.load(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if r.buf != nil {
+		if r.pos >= int64(len(r.buf)) {
+			return 0, io.EOF
+		}
+		n := copy(p, r.buf[r.pos:])
+		r.pos += int64(n)
+		return n, nil
+	}
+	n, err := r.zr.Read(p)
+	r.zpos += int64(n)
+	r.pos = r.zpos
+	return n, err
+}
+
+func (r *compressedFileReader) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += int64(r.c.Size)
+	case io.SeekStart:
+	default:
+		return 0, errors.New("asar: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("asar: negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func (r *compressedFileReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("asar: negative offset")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.buf == nil {
+		if err := r.load(); err != nil {
+			return 0, err
+		}
+	}
+	if off >= int64(len(r.buf)) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.buf[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// load 重新解压并将完整内容读入内存，替换当前的解压流
+func (r *compressedFileReader) load() error {
+	data, err := readCompressed(io.NewSectionReader(r.raw, 0, r.stored), r.c)
+	if err != nil {
+		return err
+	}
+	r.zr.Close()
+	r.buf = data
+	return nil
+}
+
+func (r *compressedFileReader) Close() error {
+	r.zr.Close()
+	return r.raw.Close()
+}
+
+// compress 读取并关闭 r，以 algorithm 压缩后暂存；返回压缩数据对应的区段、原始内容的完整性信息与原始大小
+func (s *transformSpool) compress(r io.ReadCloser, algorithm string) (*io.SectionReader, FileIntegrity, int64, error) {
+	defer r.Close()
+	if err := s.open(); err != nil {
+		return nil, FileIntegrity{}, 0, err
+	}
+	cw := &countWriter{w: io.NewOffsetWriter(s.f, s.offset)}
+	zw, err := newCompressor(algorithm, cw)
+	if err != nil {
+		return nil, FileIntegrity{}, 0, err
+	}
+	iw := NewIntegrityWriter()
+	n, err := copyBuffer(io.MultiWriter(zw, iw), r)
+	if err != nil {
+		return nil, FileIntegrity{}, 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, FileIntegrity{}, 0, err
+	}
+	sec := io.NewSectionReader(s.f, s.offset, cw.n)
+	s.offset += cw.n
+	return sec, iw.Integrity(), n, nil
+}
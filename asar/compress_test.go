@@ -0,0 +1,83 @@
+package asar
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCompressedFileReader(t *testing.T) {
+	src := testTree(t)
+	archive := packTree(t, src, CreateOptions{Compress: "*.js"})
+	want, err := os.ReadFile(filepath.Join(src, "dir", "sub", "c.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := loadFilesystem(archive, ReadOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, _ := fsys.GetFile("dir/sub/c.js", false)
+	f, err := OpenFileSync(fsys, "dir/sub/c.js", e.(*FilesystemFileEntry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// iotest.TestReader 覆盖顺序读取、Seek 与 ReadAt
+	if err := iotest.TestReader(f, want); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := OpenReader(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	rc, err := r.OpenFile("dir/sub/c.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if err := iotest.TestReader(rc, want); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompressedFileStreams(t *testing.T) {
+	src := t.TempDir()
+	const size = 32 << 20
+	writeTree(t, src, map[string]string{"big.js": strings.Repeat("function f() { return 42; }\n", size/28)})
+	archive := packTree(t, src, CreateOptions{Compress: "*.js"})
+	fsys, err := loadFilesystem(archive, ReadOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, _ := fsys.GetFile("big.js", false)
+	fe := e.(*FilesystemFileEntry)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	f, err := OpenFileSync(fsys, "big.js", fe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// 向前 Seek 与顺序读取不会把内容读入内存
+	if _, err := f.Seek(size/2, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	n, err := io.Copy(io.Discard, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	if n != contentSize(fe)-size/2 {
+		t.Fatalf("read %d bytes, want %d", n, contentSize(fe)-size/2)
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > size/4 {
+		t.Fatalf("reading a %d byte compressed file allocated %d bytes", size, alloc)
+	}
+}
@@ -1,9 +1,12 @@
 package asar
 
 import (
+	"errors"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // CrawledFileType 表示爬取到的条目信息
@@ -27,38 +30,120 @@ func DetermineFileType(filename string) (*CrawledFileType, error) {
 	return &CrawledFileType{Type: "file", Stat: fi}, nil
 }
 
+// ReadLinkFS 可读取符号链接的文件系统，方法签名与 Go 1.25 的 fs.ReadLinkFS 一致，
+// 实现了 fs.ReadLinkFS 的来源（如 Go 1.25+ 的 os.DirFS）可直接保留符号链接
+type ReadLinkFS interface {
+	fs.FS
+	ReadLink(name string) (string, error)
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// DetermineFileTypeFS 判断 fs.FS 中条目的类型。来源不支持 ReadLinkFS 时无法读取链接目标，条目按 fs.Stat 的结果处理：
+// Stat 会解析链接的来源按链接目标处理，Stat 仍报告为符号链接的条目（如 zip.Reader 中的链接）按普通文件打包其内容
+func DetermineFileTypeFS(fsys fs.FS, name string) (*CrawledFileType, error) {
+	var fi fs.FileInfo
+	var err error
+	lfs, isLinkFS := fsys.(ReadLinkFS)
+	if isLinkFS {
+		fi, err = lfs.Lstat(name)
+	} else {
+		fi, err = fs.Stat(fsys, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if isLinkFS && fi.Mode()&fs.ModeSymlink != 0 {
+		return &CrawledFileType{Type: "link", Stat: fi}, nil
+	}
+	if fi.IsDir() {
+		return &CrawledFileType{Type: "directory", Stat: fi}, nil
+	}
+	return &CrawledFileType{Type: "file", Stat: fi}, nil
+}
+
 // Crawl 递归遍历目录，返回路径列表与元数据
 func Crawl(root string, includeDot bool) ([]string, map[string]*CrawledFileType, error) {
+	return CrawlWithOptions(root, CreateOptions{Dot: includeDot})
+}
+
+// CrawlWithOptions 按打包选项（Dot、Pattern、Include、Exclude、.asarignore）递归遍历目录，返回绝对路径列表与元数据
+func CrawlWithOptions(root string, options CreateOptions) ([]string, map[string]*CrawledFileType, error) {
+	filter, err := newPathFilter(options)
+	if err != nil {
+		return nil, nil, err
+	}
+	names, meta, err := crawlFS(dirFS(root), options.Dot, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	files := make([]string, 0, len(names))
+	absMeta := make(map[string]*CrawledFileType, len(meta))
+	for _, name := range names {
+		full := filepath.Join(root, filepath.FromSlash(name))
+		files = append(files, full)
+		absMeta[full] = meta[name]
+	}
+	return files, absMeta, nil
+}
+
+// CrawlFS 递归遍历 fs.FS（embed.FS、zip.Reader、fstest.MapFS 等），返回 slash 相对路径列表与元数据，
+// 隐藏文件的过滤规则与 Crawl 一致
+func CrawlFS(fsys fs.FS, includeDot bool) ([]string, map[string]*CrawledFileType, error) {
+	return CrawlFSWithOptions(fsys, CreateOptions{Dot: includeDot})
+}
+
+// CrawlFSWithOptions 按打包选项（Dot、Pattern、Include、Exclude、.asarignore）递归遍历 fs.FS
+func CrawlFSWithOptions(fsys fs.FS, options CreateOptions) ([]string, map[string]*CrawledFileType, error) {
+	filter, err := newPathFilter(options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return crawlFS(fsys, options.Dot, filter)
+}
+
+// crawlFS 遍历来源：被排除（含 .asarignore 忽略）的目录不再向下遍历；
+// 未匹配包含模式的目录本身不返回，但仍会遍历其子项
+func crawlFS(fsys fs.FS, includeDot bool, filter *pathFilter) ([]string, map[string]*CrawledFileType, error) {
 	meta := map[string]*CrawledFileType{}
 	files := make([]string, 0)
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if name == "." {
+			return filter.loadIgnoreFile(fsys, name)
+		}
 		// 过滤隐藏（任一路径段以 . 开头）
-		if !includeDot && path != root {
-			rel, _ := filepath.Rel(root, path)
-			parts := filepath.SplitList(filepath.ToSlash(rel))
-			// SplitList 不适合通用，这里按 '/'
-			parts = splitBySlash(rel)
-			for _, p := range parts {
+		if !includeDot {
+			for _, p := range strings.Split(name, "/") {
 				if len(p) > 0 && p[0] == '.' {
-					if info.IsDir() {
-						return filepath.SkipDir
+					if d.IsDir() {
+						return fs.SkipDir
 					}
 					return nil
 				}
 			}
 		}
-		if path == root {
+		if filter.excluded(name, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
 			return nil
 		}
-		typ, err := DetermineFileType(path)
+		if d.IsDir() {
+			if err := filter.loadIgnoreFile(fsys, name); err != nil {
+				return err
+			}
+		}
+		if !filter.included(name) {
+			return nil
+		}
+		typ, err := DetermineFileTypeFS(fsys, name)
 		if err != nil {
 			return err
 		}
-		meta[path] = typ
-		files = append(files, path)
+		meta[name] = typ
+		files = append(files, name)
 		return nil
 	})
 	if err != nil {
@@ -67,20 +152,50 @@ func Crawl(root string, includeDot bool) ([]string, map[string]*CrawledFileType,
 	return files, meta, nil
 }
 
-// StreamGenerator 为生成读取流的函数类型
-type StreamGenerator func() (io.ReadCloser, error)
+// dirFS 基于磁盘目录的 ReadLinkFS 实现，供目录打包复用 fs.FS 打包流程
+type dirFS string
 
-func splitBySlash(s string) []string {
-	s = filepath.ToSlash(s)
-	out := make([]string, 0)
-	cur := 0
-	b := []byte(s)
-	for i := 0; i < len(b); i++ {
-		if b[i] == '/' {
-			out = append(out, string(b[cur:i]))
-			cur = i + 1
-		}
+func (d dirFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	return os.Open(d.join(name))
+}
+
+func (d dirFS) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
 	}
-	out = append(out, string(b[cur:]))
-	return out
+	return os.Lstat(d.join(name))
 }
+
+func (d dirFS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return os.Readlink(d.join(name))
+}
+
+func (d dirFS) join(name string) string {
+	return filepath.Join(string(d), filepath.FromSlash(name))
+}
+
+// archiveLink 按真实路径计算链接目标相对于打包根目录的路径，避免源目录自身位于符号链接下时出现偏差
+func (d dirFS) archiveLink(name, target string) string {
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(mustRealpath(filepath.Dir(d.join(name))), target)
+	}
+	return relPath(mustRealpath(string(d)), target)
+}
+
+// readLinkFS 读取来源中的符号链接目标
+func readLinkFS(fsys fs.FS, name string) (string, error) {
+	lfs, ok := fsys.(ReadLinkFS)
+	if !ok {
+		return "", errors.New(name + ": symbolic links are not supported by the source filesystem")
+	}
+	return lfs.ReadLink(name)
+}
+
+// StreamGenerator 为生成读取流的函数类型
+type StreamGenerator func() (io.ReadCloser, error)
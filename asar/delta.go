@@ -0,0 +1,459 @@
+package asar
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// 补丁格式（整数均为小端）：
+//
+//	magic "ASARDLT1"
+//	旧归档头的 SHA256 [32]，新归档头的 SHA256 [32]
+//	uint32 新归档头长度，新归档头（size pickle 与 header pickle，原样）
+//	int64 新归档数据区长度
+//	数据区操作序列，以 deltaOpEnd 结束
+//	每个 unpacked 文件一个操作，按头中的路径顺序
+//	新归档整体的 SHA256 [32]
+//
+// 操作为 deltaOpCopy（uint32 长度 + 旧归档中的文件路径，复制该文件存储的全部数据，压缩的文件不解压）
+// 或 deltaOpLiteral（uint64 长度 + 原样数据）
+const deltaMagic = "ASARDLT1"
+
+const (
+	deltaOpCopy    byte = 'c'
+	deltaOpLiteral byte = 'l'
+	deltaOpEnd     byte = 'e'
+)
+
+// maxDeltaName 复制操作中文件路径的最大长度，超出视为补丁损坏
+const maxDeltaName = 64 << 10
+
+// CreateDelta 生成将 oldPath 更新为 newPath 的补丁并写入 w：内容与旧归档中某个文件相同（integrity 哈希与大小一致）的文件
+// 只记录旧路径，其余数据（包括 .unpacked 中的文件）原样写入。应用补丁的结果与 newPath 逐字节相同
+func CreateDelta(oldPath, newPath string, w io.Writer) error {
+	oldFsys, err := loadFilesystem(oldPath, ReadOptions{Strict: true})
+	if err != nil {
+		return err
+	}
+	newFsys, err := loadFilesystem(newPath, ReadOptions{Strict: true})
+	if err != nil {
+		return err
+	}
+	oldHeader, err := readHeaderBytes(oldPath, oldFsys.GetHeaderSize())
+	if err != nil {
+		return err
+	}
+	newHeader, err := readHeaderBytes(newPath, newFsys.GetHeaderSize())
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(newPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	st, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	dataStart := int64(len(newHeader))
+	dataSize := st.Size() - dataStart
+
+	// 旧归档中可复用的文件，以存储的数据为键
+	reusable := map[string]string{}
+	err = walkEntries(oldFsys.header, func(p string, entry FilesystemEntry) error {
+		if f, ok := entry.(*FilesystemFileEntry); ok && f.Integrity.Hash != "" {
+			key, err := contentKey(oldFsys, p, f)
+			if err != nil {
+				return err
+			}
+			if _, ok := reusable[key]; !ok {
+				reusable[key] = p
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	type packedFile struct {
+		name         string
+		entry        *FilesystemFileEntry
+		offset, size int64
+	}
+	var packed []packedFile
+	var unpacked []string
+	walkEntries(newFsys.header, func(p string, entry FilesystemEntry) error {
+		f, ok := entry.(*FilesystemFileEntry)
+		switch {
+		case !ok:
+		case f.Unpacked:
+			unpacked = append(unpacked, p)
+		default:
+			off, _ := strconv.ParseInt(f.Offset, 10, 64)
+			packed = append(packed, packedFile{p, f, off, int64(f.Size)})
+		}
+		return nil
+	})
+	sort.SliceStable(packed, func(i, j int) bool { return packed[i].offset < packed[j].offset })
+
+	pw := &deltaWriter{w: bufio.NewWriter(w), hash: sha256.New()}
+	pw.write([]byte(deltaMagic))
+	pw.write(sha256Sum(oldHeader))
+	pw.write(sha256Sum(newHeader))
+	pw.uint32(uint32(len(newHeader)))
+	pw.write(newHeader)
+	pw.uint64(uint64(dataSize))
+	pw.hash.Write(newHeader)
+
+	// 按偏移顺序覆盖整个数据区：文件之间的空隙与相互重叠的部分按原样写入
+	var pos int64
+	literal := func(from, to int64) {
+		pw.literal(io.NewSectionReader(in, dataStart+from, to-from), to-from)
+	}
+	for _, f := range packed {
+		end := f.offset + f.size
+		if f.offset < pos {
+			if end > pos {
+				literal(pos, end)
+				pos = end
+			}
+			continue
+		}
+		if f.offset > pos {
+			literal(pos, f.offset)
+		}
+		key, err := contentKey(newFsys, f.name, f.entry)
+		if err != nil {
+			return err
+		}
+		if name, ok := reusable[key]; ok && f.size > 0 {
+			pw.copy(name)
+			// 整体哈希仍按新归档中的实际数据计算
+			if _, err := copyBuffer(pw.hash, io.NewSectionReader(in, dataStart+f.offset, f.size)); err != nil {
+				return err
+			}
+		} else {
+			literal(f.offset, end)
+		}
+		pos = end
+	}
+	if pos < dataSize {
+		literal(pos, dataSize)
+	}
+	pw.op(deltaOpEnd)
+
+	for _, name := range unpacked {
+		node, _, _ := newFsys.findNode(name, false)
+		f := node.(*FilesystemFileEntry)
+		key, err := contentKey(newFsys, name, f)
+		if err != nil {
+			return err
+		}
+		if src, ok := reusable[key]; ok && f.Size > 0 {
+			pw.copy(src)
+			continue
+		}
+		r, err := OpenFileSync(newFsys, name, f)
+		if err != nil {
+			return &PathError{Op: "delta", Archive: newFsys.src, Path: name, Err: err}
+		}
+		pw.literalRaw(r, int64(f.Size))
+		r.Close()
+	}
+	if pw.err != nil {
+		return pw.err
+	}
+	pw.write(pw.hash.Sum(nil))
+	if pw.err != nil {
+		return pw.err
+	}
+	return pw.w.Flush()
+}
+
+// ApplyDelta 将补丁应用到 oldPath 并写入 dest（可与 oldPath 相同）。补丁与旧归档不匹配、
+// 补丁中的头或生成结果的哈希校验失败时返回 ErrPatchMismatch，dest 保持不变
+func ApplyDelta(oldPath string, patch io.Reader, dest string) error {
+	oldPath, err := filepath.Abs(oldPath)
+	if err != nil {
+		return err
+	}
+	dest, err = filepath.Abs(dest)
+	if err != nil {
+		return err
+	}
+	oldFsys, err := loadFilesystem(oldPath, ReadOptions{Strict: true})
+	if err != nil {
+		return err
+	}
+	oldHeader, err := readHeaderBytes(oldPath, oldFsys.GetHeaderSize())
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(patch)
+	var prefix [len(deltaMagic) + 2*sha256.Size + 4]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return patchError("truncated patch", err)
+	}
+	if string(prefix[:len(deltaMagic)]) != deltaMagic {
+		return patchError("not an asar delta patch", nil)
+	}
+	wantOld := prefix[len(deltaMagic) : len(deltaMagic)+sha256.Size]
+	wantNew := prefix[len(deltaMagic)+sha256.Size : len(deltaMagic)+2*sha256.Size]
+	if !bytes.Equal(sha256Sum(oldHeader), wantOld) {
+		return patchError("patch was not created from "+filepath.Base(oldPath), nil)
+	}
+	headerLen := int64(binary.LittleEndian.Uint32(prefix[len(prefix)-4:]))
+	newHeader, err := io.ReadAll(io.LimitReader(r, headerLen))
+	if err != nil {
+		return err
+	}
+	if int64(len(newHeader)) != headerLen {
+		return patchError("truncated patch", io.ErrUnexpectedEOF)
+	}
+	if !bytes.Equal(sha256Sum(newHeader), wantNew) {
+		return patchError("header hash mismatch", nil)
+	}
+	var sizeBuf [8]byte
+	if _, err := io.ReadFull(r, sizeBuf[:]); err != nil {
+		return patchError("truncated patch", err)
+	}
+	dataSize := int64(binary.LittleEndian.Uint64(sizeBuf[:]))
+	if dataSize < 0 {
+		return patchError("invalid data size", nil)
+	}
+	header, err := readArchiveHeader(bytes.NewReader(newHeader), headerLen+dataSize, ReadOptions{Strict: true})
+	if err != nil {
+		return err
+	}
+	var unpacked []string
+	walkEntries(header.Header, func(p string, entry FilesystemEntry) error {
+		if hasUnpacked(entry) && entryType(entry) != "directory" {
+			unpacked = append(unpacked, p)
+		}
+		return nil
+	})
+
+	output, err := newAtomicOutput(dest, len(unpacked) > 0)
+	if err != nil {
+		return err
+	}
+	defer output.cleanup()
+	sum := sha256.New()
+	w := io.MultiWriter(output.file, sum)
+	if _, err := w.Write(newHeader); err != nil {
+		return err
+	}
+	ar := &deltaReader{r: r, old: oldFsys}
+	var written int64
+	for {
+		op, err := r.ReadByte()
+		if err != nil {
+			return patchError("truncated patch", io.ErrUnexpectedEOF)
+		}
+		if op == deltaOpEnd {
+			break
+		}
+		n, err := ar.apply(op, w)
+		if err != nil {
+			return err
+		}
+		written += n
+	}
+	if written != dataSize {
+		return patchError("data size mismatch", nil)
+	}
+	newFsys := &Filesystem{header: header.Header}
+	for _, name := range unpacked {
+		node, _, _ := newFsys.findNode(name, false)
+		switch t := node.(type) {
+		case *FilesystemLinkEntry:
+			if err := copyUnpackedEntry(dest, output.unpacked, name, t, nil, 0); err != nil {
+				return err
+			}
+		case *FilesystemFileEntry:
+			mode := os.FileMode(0o644)
+			if t.Executable {
+				mode = 0o755
+			}
+			out, err := createUnpacked(name, output.unpacked, mode)
+			if err != nil {
+				return err
+			}
+			iw := NewIntegrityWriter()
+			op, err := r.ReadByte()
+			if err == nil {
+				_, err = ar.apply(op, io.MultiWriter(out, iw))
+			}
+			if cerr := out.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+			if iw.Integrity().Hash != t.Integrity.Hash {
+				return patchError("integrity mismatch for "+strconv.Quote(name), nil)
+			}
+		}
+	}
+	var wantSum [sha256.Size]byte
+	if _, err := io.ReadFull(r, wantSum[:]); err != nil {
+		return patchError("truncated patch", err)
+	}
+	if !bytes.Equal(sum.Sum(nil), wantSum[:]) {
+		return patchError("archive hash mismatch", nil)
+	}
+	return output.commit()
+}
+
+// deltaWriter 写出补丁，记录第一个错误
+type deltaWriter struct {
+	w    *bufio.Writer
+	hash hash.Hash // 新归档整体的哈希
+	err  error
+}
+
+func (pw *deltaWriter) write(p []byte) {
+	if pw.err == nil {
+		_, pw.err = pw.w.Write(p)
+	}
+}
+
+func (pw *deltaWriter) op(op byte) { pw.write([]byte{op}) }
+
+func (pw *deltaWriter) uint32(v uint32) { pw.write(binary.LittleEndian.AppendUint32(nil, v)) }
+
+func (pw *deltaWriter) uint64(v uint64) { pw.write(binary.LittleEndian.AppendUint64(nil, v)) }
+
+func (pw *deltaWriter) copy(name string) {
+	pw.op(deltaOpCopy)
+	pw.uint32(uint32(len(name)))
+	pw.write([]byte(name))
+}
+
+// literal 原样写入 r 中的 n 字节，并计入新归档的整体哈希
+func (pw *deltaWriter) literal(r io.Reader, n int64) {
+	pw.literalRaw(io.TeeReader(r, pw.hash), n)
+}
+
+// literalRaw 原样写入 r 中的 n 字节（unpacked 文件不计入整体哈希）
+func (pw *deltaWriter) literalRaw(r io.Reader, n int64) {
+	pw.op(deltaOpLiteral)
+	pw.uint64(uint64(n))
+	if pw.err != nil {
+		return
+	}
+	copied, err := copyBuffer(pw.w, io.LimitReader(r, n))
+	if err == nil && copied != n {
+		err = io.ErrUnexpectedEOF
+	}
+	pw.err = err
+}
+
+// deltaReader 执行补丁中的操作
+type deltaReader struct {
+	r   *bufio.Reader
+	old *Filesystem
+}
+
+// apply 执行一个操作并返回写入的字节数
+func (ar *deltaReader) apply(op byte, w io.Writer) (int64, error) {
+	switch op {
+	case deltaOpCopy:
+		var lenBuf [4]byte
+		if _, err := io.ReadFull(ar.r, lenBuf[:]); err != nil {
+			return 0, patchError("truncated patch", err)
+		}
+		nameLen := binary.LittleEndian.Uint32(lenBuf[:])
+		if nameLen > maxDeltaName {
+			return 0, patchError("invalid copy operation", nil)
+		}
+		name := make([]byte, nameLen)
+		if _, err := io.ReadFull(ar.r, name); err != nil {
+			return 0, patchError("truncated patch", err)
+		}
+		node, _, err := ar.old.findNode(string(name), false)
+		f, ok := node.(*FilesystemFileEntry)
+		if err != nil || !ok {
+			return 0, patchError("patch references missing file "+strconv.Quote(string(name)), nil)
+		}
+		in, err := openStored(ar.old, string(name), f)
+		if err != nil {
+			return 0, pathError("apply", ar.old.src, string(name), err)
+		}
+		defer in.Close()
+		n, err := copyBuffer(w, io.LimitReader(in, int64(f.Size)))
+		if err == nil && n != int64(f.Size) {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	case deltaOpLiteral:
+		var lenBuf [8]byte
+		if _, err := io.ReadFull(ar.r, lenBuf[:]); err != nil {
+			return 0, patchError("truncated patch", err)
+		}
+		size := int64(binary.LittleEndian.Uint64(lenBuf[:]))
+		n, err := copyBuffer(w, io.LimitReader(ar.r, size))
+		if err == nil && n != size {
+			return n, patchError("truncated patch", io.ErrUnexpectedEOF)
+		}
+		return n, err
+	}
+	return 0, patchError("unknown operation "+strconv.Itoa(int(op)), nil)
+}
+
+// readHeaderBytes 读取归档开头的 size pickle 与 header pickle
+func readHeaderBytes(archivePath string, headerSize int) ([]byte, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, 8+headerSize)
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// contentKey 标识文件在归档中存储的数据：未压缩的文件以 integrity 哈希与大小为键；
+// 压缩的文件即使原始内容相同，压缩结果也可能不同（压缩级别、gzip 头等），因此对存储的数据计算哈希
+func contentKey(fsys *Filesystem, name string, f *FilesystemFileEntry) (string, error) {
+	if f.Compression == nil {
+		return f.Integrity.Hash + ":" + strconv.Itoa(f.Size), nil
+	}
+	r, err := openStored(fsys, name, f)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	h := sha256.New()
+	if _, err := copyBuffer(h, r); err != nil {
+		return "", &PathError{Op: "delta", Archive: fsys.src, Path: name, Err: err}
+	}
+	return "stored:" + hex.EncodeToString(h.Sum(nil)) + ":" + strconv.Itoa(f.Size), nil
+}
+
+func sha256Sum(p []byte) []byte {
+	sum := sha256.Sum256(p)
+	return sum[:]
+}
+
+// patchError 返回 ErrPatchMismatch 类别的错误
+func patchError(msg string, cause error) error {
+	msg = ErrPatchMismatch.Error() + ": " + msg
+	if cause != nil {
+		msg += ": " + cause.Error()
+	}
+	return &kindError{msg: msg, kind: ErrPatchMismatch, cause: cause}
+}
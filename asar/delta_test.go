@@ -0,0 +1,107 @@
+package asar
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDeltaRoundTrip(t *testing.T) {
+	src := testTree(t)
+	writeTree(t, src, map[string]string{"big.txt": strings.Repeat("unchanged content\n", 8192)})
+	old := packTree(t, src, CreateOptions{UnpackDir: "assets", Compress: "*.js"})
+	writeTree(t, src, map[string]string{"a.txt": "changed", "new.txt": "new", "assets/img.png": "unpacked change"})
+	newArchive := packTree(t, src, CreateOptions{UnpackDir: "assets", Compress: "*.js"})
+
+	var patch bytes.Buffer
+	if err := CreateDelta(old, newArchive, &patch); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(newArchive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 未变化的文件只记录路径，补丁明显小于新归档
+	if patch.Len() >= len(want)/2 {
+		t.Errorf("patch is %d bytes for a %d byte archive", patch.Len(), len(want))
+	}
+
+	out := filepath.Join(t.TempDir(), "out.asar")
+	if err := ApplyDelta(old, bytes.NewReader(patch.Bytes()), out); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("patched archive differs from the new archive")
+	}
+	dest := t.TempDir()
+	if err := ExtractAll(out, dest); err != nil {
+		t.Fatal(err)
+	}
+	compareTree(t, src, dest)
+
+	// 应用到不匹配的归档或被篡改的补丁时失败
+	if err := ApplyDelta(newArchive, bytes.NewReader(patch.Bytes()), filepath.Join(t.TempDir(), "x.asar")); !errors.Is(err, ErrPatchMismatch) {
+		t.Errorf("wrong base archive: err = %v, want ErrPatchMismatch", err)
+	}
+	bad := bytes.Clone(patch.Bytes())
+	bad[len(bad)-1] ^= 0xff
+	if err := ApplyDelta(old, bytes.NewReader(bad), filepath.Join(t.TempDir(), "x.asar")); !errors.Is(err, ErrPatchMismatch) {
+		t.Errorf("tampered patch: err = %v, want ErrPatchMismatch", err)
+	}
+}
+
+func TestDeltaCompressedBytesDiffer(t *testing.T) {
+	src := testTree(t)
+	old := packTree(t, src, CreateOptions{Compress: "*.js"})
+
+	// 同样的内容以不同的压缩字节存储：改写 gzip 头中的 OS 字段，解压结果不变
+	data, err := os.ReadFile(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := loadFilesystem(old, ReadOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, _, err := fsys.findNode("dir/sub/c.js", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := node.(*FilesystemFileEntry)
+	if f.Compression == nil {
+		t.Fatal("dir/sub/c.js is not compressed")
+	}
+	offset, err := strconv.ParseInt(f.Offset, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[8+int64(fsys.headerSize)+offset+9] ^= 0xff
+	newArchive := filepath.Join(t.TempDir(), "new.asar")
+	if err := os.WriteFile(newArchive, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var patch bytes.Buffer
+	if err := CreateDelta(old, newArchive, &patch); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "out.asar")
+	if err := ApplyDelta(old, bytes.NewReader(patch.Bytes()), out); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("patched archive differs from the new archive")
+	}
+}
@@ -0,0 +1,273 @@
+package asar
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// DiffKind 差异类型
+type DiffKind string
+
+const (
+	DiffAdded         DiffKind = "added"
+	DiffRemoved       DiffKind = "removed"
+	DiffModified      DiffKind = "modified"       // 文件内容或链接目标不同
+	DiffTypeChanged   DiffKind = "type-changed"   // 文件、目录、链接之间互相替换
+	DiffModeChanged   DiffKind = "mode-changed"   // 可执行位不同
+	DiffUnpackChanged DiffKind = "unpack-changed" // 打包/解包状态不同
+)
+
+// DiffEntry 描述一处差异；同一路径可能同时出现多种差异（如 modified 与 mode-changed）
+type DiffEntry struct {
+	Path string   `json:"path"`
+	Kind DiffKind `json:"kind"`
+	// Old 与 New 为变化前后的取值：条目类型（file/directory/link）、
+	// 文件的 SHA256、链接目标、regular/executable 或 packed/unpacked
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// DiffOptions 比较选项
+type DiffOptions struct {
+	// Pack 为目录一侧使用的打包选项（Dot、Pattern、Include、Exclude、Unpack、UnpackDir 等），
+	// 使目录按打包后的结果参与比较；Transform 不生效
+	Pack CreateOptions
+}
+
+// Diff 比较两个归档，或归档与目录（任一侧均可为目录），返回按路径排序的差异。
+// 文件内容按头中的 integrity 比较，目录一侧（以及缺少 integrity 的归档文件）在大小相同时即时计算哈希
+func Diff(oldPath, newPath string, options DiffOptions) ([]DiffEntry, error) {
+	a, err := openDiffSource(oldPath, options.Pack)
+	if err != nil {
+		return nil, err
+	}
+	b, err := openDiffSource(newPath, options.Pack)
+	if err != nil {
+		return nil, err
+	}
+	d := &differ{a: a, b: b, diffs: make([]DiffEntry, 0)}
+	if err := d.dir("", a.root, b.root); err != nil {
+		return nil, err
+	}
+	return d.diffs, nil
+}
+
+// diffSource 比较的一侧：归档或按打包选项解释的目录
+type diffSource struct {
+	root *FilesystemDirectoryEntry
+	fsys *Filesystem // 归档一侧
+	dir  string      // 目录一侧
+}
+
+func openDiffSource(p string, options CreateOptions) (*diffSource, error) {
+	st, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !st.IsDir() {
+		fsys, err := loadFilesystem(p, ReadOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &diffSource{root: fsys.header.(*FilesystemDirectoryEntry), fsys: fsys}, nil
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return nil, err
+	}
+	root, err := dirHeader(abs, options)
+	if err != nil {
+		return nil, err
+	}
+	return &diffSource{root: root, dir: abs}, nil
+}
+
+// dirHeader 按打包规则构建目录对应的头，文件的 integrity 留空，比较时按需计算
+func dirHeader(dir string, options CreateOptions) (*FilesystemDirectoryEntry, error) {
+	if options.Pattern == "" && len(options.Include) == 0 {
+		options.Pattern = "/**/*"
+	}
+	fsys := dirFS(dir)
+	names, meta, err := CrawlFSWithOptions(fsys, options)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := newUnpackRules(options)
+	if err != nil {
+		return nil, err
+	}
+	root := &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{}}
+	for _, name := range names {
+		m := meta[name]
+		switch m.Type {
+		case "directory":
+			ensureDir(root, name, rules.dir(name))
+		case "file":
+			fe := &FilesystemFileEntry{Unpacked: rules.file(name), Size: int(m.Stat.Size())}
+			fe.Executable = executableMode(m.Stat.Mode())
+			ensureDir(root, path.Dir(name), false)[path.Base(name)] = fe
+		case "link":
+			target, err := fsys.ReadLink(name)
+			if err != nil {
+				return nil, err
+			}
+			le := &FilesystemLinkEntry{Link: fsys.archiveLink(name, target), EntryMetadata: EntryMetadata{Unpacked: rules.link(name)}}
+			ensureDir(root, path.Dir(name), false)[path.Base(name)] = le
+		}
+	}
+	return root, nil
+}
+
+// hash 返回文件内容的 SHA256，头中没有记录时读取内容计算
+func (s *diffSource) hash(name string, f *FilesystemFileEntry) (string, error) {
+	if s.fsys != nil && f.Integrity.Hash != "" {
+		return f.Integrity.Hash, nil
+	}
+	var r FileReader
+	var err error
+	if s.fsys != nil {
+		r, err = OpenFileSync(s.fsys, name, f)
+	} else {
+		r, err = os.Open(filepath.Join(s.dir, filepath.FromSlash(name)))
+	}
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	integ, err := GetFileIntegrity(r)
+	if err != nil {
+		return "", err
+	}
+	return integ.Hash, nil
+}
+
+type differ struct {
+	a, b  *diffSource
+	diffs []DiffEntry
+}
+
+func (d *differ) add(p string, kind DiffKind, from, to string) {
+	d.diffs = append(d.diffs, DiffEntry{Path: p, Kind: kind, Old: from, New: to})
+}
+
+// dir 比较两个目录的子项
+func (d *differ) dir(prefix string, a, b *FilesystemDirectoryEntry) error {
+	names := make([]string, 0, len(a.Files)+len(b.Files))
+	for name := range a.Files {
+		names = append(names, name)
+	}
+	for name := range b.Files {
+		if _, ok := a.Files[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := name
+		if prefix != "" {
+			p = prefix + "/" + name
+		}
+		if err := d.entry(p, a.Files[name], b.Files[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entry 比较同一路径上的两个条目，任一侧为 nil 表示不存在
+func (d *differ) entry(p string, a, b FilesystemEntry) error {
+	switch {
+	case a == nil:
+		d.subtree(p, b, DiffAdded)
+		return nil
+	case b == nil:
+		d.subtree(p, a, DiffRemoved)
+		return nil
+	case entryType(a) != entryType(b):
+		d.add(p, DiffTypeChanged, entryType(a), entryType(b))
+		d.children(p, a, DiffRemoved)
+		d.children(p, b, DiffAdded)
+		return nil
+	}
+	if hasUnpacked(a) != hasUnpacked(b) {
+		d.add(p, DiffUnpackChanged, packState(a), packState(b))
+	}
+	switch ta := a.(type) {
+	case *FilesystemDirectoryEntry:
+		return d.dir(p, ta, b.(*FilesystemDirectoryEntry))
+	case *FilesystemLinkEntry:
+		if tb := b.(*FilesystemLinkEntry); ta.Link != tb.Link {
+			d.add(p, DiffModified, ta.Link, tb.Link)
+		}
+	case *FilesystemFileEntry:
+		tb := b.(*FilesystemFileEntry)
+		// 原始大小不同即可判定为修改，此时只报告头中已有的哈希，不读取内容
+		ha, hb := ta.Integrity.Hash, tb.Integrity.Hash
+		sameSize := contentSize(ta) == contentSize(tb)
+		if sameSize {
+			var err error
+			if ha, err = d.a.hash(p, ta); err != nil {
+				return &PathError{Op: "diff", Path: p, Err: err}
+			}
+			if hb, err = d.b.hash(p, tb); err != nil {
+				return &PathError{Op: "diff", Path: p, Err: err}
+			}
+		}
+		if !sameSize || ha != hb {
+			d.add(p, DiffModified, ha, hb)
+		}
+		if ta.Executable != tb.Executable {
+			d.add(p, DiffModeChanged, fileMode(ta), fileMode(tb))
+		}
+	}
+	return nil
+}
+
+// subtree 将 e 及其全部子项记为 kind
+func (d *differ) subtree(p string, e FilesystemEntry, kind DiffKind) {
+	if kind == DiffAdded {
+		d.add(p, kind, "", entryType(e))
+	} else {
+		d.add(p, kind, entryType(e), "")
+	}
+	d.children(p, e, kind)
+}
+
+// children 将目录 e 的全部子项记为 kind，e 不是目录时不做任何事
+func (d *differ) children(p string, e FilesystemEntry, kind DiffKind) {
+	walkEntries(e, func(rel string, child FilesystemEntry) error {
+		if kind == DiffAdded {
+			d.add(p+"/"+rel, kind, "", entryType(child))
+		} else {
+			d.add(p+"/"+rel, kind, entryType(child), "")
+		}
+		return nil
+	})
+}
+
+func entryType(e FilesystemEntry) string {
+	switch e.(type) {
+	case *FilesystemDirectoryEntry:
+		return "directory"
+	case *FilesystemLinkEntry:
+		return "link"
+	default:
+		return "file"
+	}
+}
+
+func packState(e FilesystemEntry) string {
+	if hasUnpacked(e) {
+		return "unpacked"
+	}
+	return "packed"
+}
+
+func fileMode(f *FilesystemFileEntry) string {
+	if f.Executable {
+		return "executable"
+	}
+	return "regular"
+}
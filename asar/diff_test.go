@@ -0,0 +1,56 @@
+package asar
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffRoundTrip(t *testing.T) {
+	src := testTree(t)
+	old := packTree(t, src, CreateOptions{})
+	options := DiffOptions{Pack: CreateOptions{Dot: true}}
+	if diffs, err := Diff(old, src, options); err != nil || len(diffs) != 0 {
+		t.Fatalf("archive vs its source: %v, %v", diffs, err)
+	}
+
+	writeTree(t, src, map[string]string{"a.txt": "changed", "new/c.txt": "new"})
+	if err := os.Remove(filepath.Join(src, "dir", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(src, "dir", "run.sh"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fromDir, err := Diff(old, src, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"a.txt modified",
+		"dir/b.txt removed",
+		"dir/run.sh mode-changed",
+		"new added",
+		"new/c.txt added",
+	}
+	if got := diffKinds(fromDir); !reflect.DeepEqual(got, want) {
+		t.Errorf("diff = %q, want %q", got, want)
+	}
+	// 与打包后的新归档比较得到相同的差异
+	fromArchive, err := Diff(old, packTree(t, src, CreateOptions{}), DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diffKinds(fromArchive); !reflect.DeepEqual(got, want) {
+		t.Errorf("archive diff = %q, want %q", got, want)
+	}
+}
+
+// diffKinds 将差异格式化为 "路径 类型"
+func diffKinds(diffs []DiffEntry) []string {
+	var s []string
+	for _, d := range diffs {
+		s = append(s, d.Path+" "+string(d.Kind))
+	}
+	return s
+}
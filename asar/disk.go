@@ -1,9 +1,11 @@
 package asar
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -30,71 +32,129 @@ type ArchiveHeader struct {
 	Header       FilesystemEntry
 	HeaderString string
 	HeaderSize   int
+	// Invalid 为加载时被丢弃的不合法条目（Header 中已不包含它们）
+	Invalid []*InvalidEntryError
 }
 
-// ReadArchiveHeaderSync 同步读取 ASAR 头（解析 JSON）
+// ReadArchiveHeaderSync 同步读取 ASAR 头（解析 JSON），不合法条目会被丢弃并记录在 Invalid 中
 func ReadArchiveHeaderSync(archivePath string) (ArchiveHeader, error) {
+	return ReadArchiveHeaderWithOptions(archivePath, ReadOptions{})
+}
+
+// ReadArchiveHeaderWithOptions 按选项读取并校验 ASAR 头
+func ReadArchiveHeaderWithOptions(archivePath string, options ReadOptions) (ArchiveHeader, error) {
 	f, err := os.Open(archivePath)
 	if err != nil {
 		return ArchiveHeader{}, err
 	}
 	defer f.Close()
+	size := int64(-1)
+	if st, err := f.Stat(); err == nil && st.Mode().IsRegular() {
+		size = st.Size()
+	}
+	return readArchiveHeader(f, size, options)
+}
+
+// readArchiveHeader 从归档起始位置读取 size pickle 与 header pickle 并校验条目；
+// size 为归档总字节数，小于 0 表示未知
+func readArchiveHeader(r io.Reader, size int64, options ReadOptions) (ArchiveHeader, error) {
 	sizeBuf := make([]byte, 8)
-	if _, err := io.ReadFull(f, sizeBuf); err != nil {
-		return ArchiveHeader{}, errors.New("unable to read header size")
+	if _, err := io.ReadFull(r, sizeBuf); err != nil {
+		return ArchiveHeader{}, corruptHeader("truncated header size prefix", err)
+	}
+	sizePickle, err := NewPickleFromBuffer(sizeBuf)
+	if err != nil {
+		return ArchiveHeader{}, corruptHeader("invalid header size prefix", err)
+	}
+	size32, err := sizePickle.NewIterator().ReadUInt32()
+	if err != nil {
+		return ArchiveHeader{}, corruptHeader("invalid header size prefix", err)
+	}
+	headerSize := int(size32)
+	if limit := options.maxHeaderSize(); limit >= 0 && int64(headerSize) > limit {
+		return ArchiveHeader{}, &LimitError{Limit: "header size", Value: int64(headerSize), Max: limit}
+	}
+	if size >= 0 && int64(headerSize) > size-8 {
+		return ArchiveHeader{}, corruptHeader("truncated header: expected "+strconv.Itoa(headerSize)+" bytes, archive has "+strconv.FormatInt(size-8, 10), io.ErrUnexpectedEOF)
+	}
+	// 不预先按声明的大小分配，随实际读到的数据增长，避免伪造的大小前缀耗尽内存
+	headerBuf, err := io.ReadAll(io.LimitReader(r, int64(headerSize)))
+	if err != nil {
+		return ArchiveHeader{}, err
+	}
+	if len(headerBuf) != headerSize {
+		return ArchiveHeader{}, corruptHeader("truncated header: expected "+strconv.Itoa(headerSize)+" bytes, got "+strconv.Itoa(len(headerBuf)), io.ErrUnexpectedEOF)
+	}
+	headerPickle, err := NewPickleFromBuffer(headerBuf)
+	if err != nil {
+		return ArchiveHeader{}, corruptHeader("invalid header pickle", err)
+	}
+	if payload := headerPickle.getPayloadSize(); payload+sizeUint32 != headerSize {
+		return ArchiveHeader{}, corruptHeader("header size mismatch: size prefix is "+strconv.Itoa(headerSize)+" bytes but header payload is "+strconv.Itoa(payload)+" bytes", nil)
 	}
-	sizePickle := NewPickleFromBuffer(sizeBuf)
-	size := int(sizePickle.NewIterator().ReadUInt32())
-	headerBuf := make([]byte, size)
-	if _, err := io.ReadFull(f, headerBuf); err != nil {
-		return ArchiveHeader{}, errors.New("unable to read header")
+	headerStr, err := headerPickle.NewIterator().ReadString()
+	if err != nil {
+		return ArchiveHeader{}, corruptHeader("invalid header pickle", err)
 	}
-	headerPickle := NewPickleFromBuffer(headerBuf)
-	headerStr := headerPickle.NewIterator().ReadString()
-	hdr, err := decodeHeader([]byte(headerStr))
+	hdr, err := decodeHeader([]byte(headerStr), newHeaderLimits(options))
 	if err != nil {
 		return ArchiveHeader{}, err
 	}
-	return ArchiveHeader{Header: hdr, HeaderString: headerStr, HeaderSize: size}, nil
+	dataSize := int64(-1)
+	if size >= 0 {
+		dataSize = size - 8 - int64(headerSize)
+	}
+	invalid := validateHeader(hdr.(*FilesystemDirectoryEntry), dataSize)
+	if options.Strict && len(invalid) > 0 {
+		return ArchiveHeader{}, invalid[0]
+	}
+	return ArchiveHeader{Header: hdr, HeaderString: headerStr, HeaderSize: headerSize, Invalid: invalid}, nil
 }
 
-var filesystemCache = map[string]*Filesystem{}
-
-// ReadFilesystemSync 读取并缓存文件系统头
-func ReadFilesystemSync(archivePath string) (*Filesystem, error) {
-	if fsys, ok := filesystemCache[archivePath]; ok && fsys != nil {
-		return fsys, nil
-	}
-	header, err := ReadArchiveHeaderSync(archivePath)
+// loadFilesystem 按选项读取归档头并创建不经过缓存的 Filesystem
+func loadFilesystem(archivePath string, options ReadOptions) (*Filesystem, error) {
+	header, err := ReadArchiveHeaderWithOptions(archivePath, options)
 	if err != nil {
 		return nil, err
 	}
 	fsys := NewFilesystem(archivePath)
 	fsys.SetHeader(header.Header, header.HeaderSize)
-	filesystemCache[archivePath] = fsys
+	fsys.invalid = header.Invalid
 	return fsys, nil
 }
 
+// ReadFilesystemSync 读取并缓存文件系统头（使用全局缓存，可并发调用）
+func ReadFilesystemSync(archivePath string) (*Filesystem, error) {
+	return defaultCache.ReadFilesystem(archivePath)
+}
+
 // UncacheFilesystem 清理指定缓存
 func UncacheFilesystem(archivePath string) bool {
-	if _, ok := filesystemCache[archivePath]; ok {
-		filesystemCache[archivePath] = nil
-		return true
-	}
-	return false
+	return defaultCache.Uncache(archivePath)
 }
 
 // UncacheAll 清理所有缓存
-func UncacheAll() { filesystemCache = map[string]*Filesystem{} }
+func UncacheAll() { defaultCache.UncacheAll() }
 
-// ReadFileSync 读取单个文件内容（根据文件条目信息）
+// ReadFileSync 读取单个文件内容（根据文件条目信息），压缩存储的文件返回解压后的内容
 func ReadFileSync(fsys *Filesystem, filename string, info *FilesystemFileEntry) ([]byte, error) {
+	if info.Compression != nil && !info.Unpacked {
+		raw, err := openStored(fsys, filename, info)
+		if err != nil {
+			return nil, err
+		}
+		return readCompressed(raw, info.Compression)
+	}
 	buffer := make([]byte, info.Size)
 	if info.Size <= 0 {
 		return buffer, nil
 	}
 	if info.Unpacked {
-		return os.ReadFile(filepath.Join(fsys.GetRootPath()+".unpacked", filename))
+		p, err := unpackedPath(fsys.GetRootPath(), filename)
+		if err != nil {
+			return nil, err
+		}
+		return os.ReadFile(p)
 	}
 	fd, err := os.Open(fsys.GetRootPath())
 	if err != nil {
@@ -110,14 +170,169 @@ func ReadFileSync(fsys *Filesystem, filename string, info *FilesystemFileEntry)
 	return buffer, nil
 }
 
-// createFilesystemWriteStream 创建输出文件并写入 size 与 header pickle
-func createFilesystemWriteStream(fsys *Filesystem, dest string) (*os.File, error) {
-	headerPickle := NewEmptyPickle()
-	// 将 header 序列化为 JSON
-	bs, err := json.Marshal(fsys.GetHeader())
+// FileReader 归档内单个文件的只读流
+type FileReader interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+}
+
+// OpenFileSync 以流的方式打开单个文件（根据文件条目信息），不会一次性读入内存；
+// 打包内容返回归档上的区段，unpacked 内容直接打开 .unpacked 目录下的文件，调用方负责 Close。
+// 压缩存储的文件边读边解压，只有向后 Seek 或调用 ReadAt 时才将解压后的内容读入内存
+func OpenFileSync(fsys *Filesystem, filename string, info *FilesystemFileEntry) (FileReader, error) {
+	raw, err := openStored(fsys, filename, info)
+	if err != nil || info.Compression == nil || info.Unpacked {
+		return raw, err
+	}
+	r, err := newCompressedFileReader(raw, int64(info.Size), info.Compression)
+	if err != nil {
+		return nil, pathError("open", fsys.GetRootPath(), filename, err)
+	}
+	return r, nil
+}
+
+// openContent 以流的方式打开文件的原始内容，压缩存储的文件边读边解压，调用方负责 Close
+func openContent(fsys *Filesystem, filename string, info *FilesystemFileEntry) (io.ReadCloser, error) {
+	raw, err := openStored(fsys, filename, info)
+	if err != nil || info.Compression == nil || info.Unpacked {
+		return raw, err
+	}
+	zr, err := newDecompressReader(raw, info.Compression)
+	if err != nil {
+		return nil, pathError("open", fsys.GetRootPath(), filename, err)
+	}
+	return zr, nil
+}
+
+// openStored 打开文件在归档中存储的数据（压缩存储的文件不解压）
+func openStored(fsys *Filesystem, filename string, info *FilesystemFileEntry) (FileReader, error) {
+	if info.Unpacked {
+		p, err := unpackedPath(fsys.GetRootPath(), filename)
+		if err != nil {
+			return nil, err
+		}
+		return os.Open(p)
+	}
+	off, err := strconv.ParseInt(info.Offset, 10, 64)
+	if err != nil && info.Size > 0 {
+		return nil, &PathError{Op: "open", Archive: fsys.GetRootPath(), Path: filename, Err: corruptHeader("invalid offset \""+info.Offset+"\"", nil)}
+	}
+	fd, err := os.Open(fsys.GetRootPath())
+	if err != nil {
+		return nil, &PathError{Op: "open", Archive: fsys.GetRootPath(), Path: filename, Err: err}
+	}
+	offset := int64(8+fsys.GetHeaderSize()) + off
+	return &archiveFileReader{SectionReader: io.NewSectionReader(fd, offset, int64(info.Size)), f: fd}, nil
+}
+
+// unpackedPath 返回归档内路径在 .unpacked 目录中的位置，拒绝越出该目录的路径
+func unpackedPath(archivePath, filename string) (string, error) {
+	root := archivePath + ".unpacked"
+	p := filepath.Join(root, filepath.FromSlash(filename))
+	if p == root || isOutOf(root, p) {
+		return "", &PathError{Op: "open", Archive: archivePath, Path: filename, Err: ErrLinkEscapes}
+	}
+	return p, nil
+}
+
+// archiveFileReader 为打包文件的区段读取器，Close 时关闭归档句柄
+type archiveFileReader struct {
+	*io.SectionReader
+	f *os.File
+}
+
+func (r *archiveFileReader) Close() error { return r.f.Close() }
+
+// atomicOutput 将归档与 .unpacked 先写入 dest 所在目录下的临时文件与临时目录，
+// commit 时再重命名到位；失败或中断时由 cleanup 删除临时内容，避免留下不完整的归档
+type atomicOutput struct {
+	dest      string
+	file      *os.File
+	unpacked  string // 临时 .unpacked 目录，不需要时为空
+	stale     bool   // 新归档没有 .unpacked，提交时删除 dest 旁已有的 .unpacked
+	committed bool
+}
+
+// newAtomicOutput 创建临时输出；withUnpacked 为 true 时同时创建临时 .unpacked 目录
+func newAtomicOutput(dest string, withUnpacked bool) (*atomicOutput, error) {
+	dir, base := filepath.Dir(dest), filepath.Base(dest)
+	f, err := os.CreateTemp(dir, "."+base+".*.tmp")
 	if err != nil {
 		return nil, err
 	}
+	o := &atomicOutput{dest: dest, file: f, stale: !withUnpacked}
+	// 与 os.Create 的常见结果保持一致；覆盖已有归档时沿用其权限
+	mode := fs.FileMode(0o644)
+	if st, err := os.Stat(dest); err == nil && st.Mode().IsRegular() {
+		mode = st.Mode().Perm()
+	}
+	f.Chmod(mode)
+	if withUnpacked {
+		o.unpacked, err = os.MkdirTemp(dir, "."+base+".unpacked.*.tmp")
+		if err != nil {
+			o.cleanup()
+			return nil, err
+		}
+		os.Chmod(o.unpacked, 0o755)
+	}
+	return o, nil
+}
+
+// commit 依次将 .unpacked 与归档重命名到位，已有的 .unpacked 会被整体替换；
+// 新归档没有 .unpacked 时删除旧的 .unpacked，避免其与新归档并存
+func (o *atomicOutput) commit() error {
+	if err := o.file.Close(); err != nil {
+		return err
+	}
+	if o.unpacked != "" {
+		final := o.dest + ".unpacked"
+		old := ""
+		if _, err := os.Lstat(final); err == nil {
+			old = o.unpacked + ".old"
+			if err := os.Rename(final, old); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(o.unpacked, final); err != nil {
+			if old != "" {
+				os.Rename(old, final)
+			}
+			return err
+		}
+		o.unpacked = ""
+		if old != "" {
+			defer os.RemoveAll(old)
+		}
+	}
+	if err := os.Rename(o.file.Name(), o.dest); err != nil {
+		return err
+	}
+	o.committed = true
+	if o.stale {
+		os.RemoveAll(o.dest + ".unpacked")
+	}
+	return nil
+}
+
+// cleanup 删除尚未提交的临时文件与目录，可重复调用
+func (o *atomicOutput) cleanup() {
+	if o.committed {
+		return
+	}
+	o.file.Close()
+	os.Remove(o.file.Name())
+	if o.unpacked != "" {
+		os.RemoveAll(o.unpacked)
+	}
+}
+
+// writeFilesystemHeader 将 header 序列化为 JSON 并依次写入 size pickle 与 header pickle
+func writeFilesystemHeader(w io.Writer, header FilesystemEntry) error {
+	headerPickle := NewEmptyPickle()
+	bs, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
 	headerPickle.WriteString(string(bs))
 	headerBuf := headerPickle.ToBuffer()
 
@@ -125,24 +340,15 @@ func createFilesystemWriteStream(fsys *Filesystem, dest string) (*os.File, error
 	sizePickle.WriteUInt32(uint32(len(headerBuf)))
 	sizeBuf := sizePickle.ToBuffer()
 
-	out, err := os.Create(dest)
-	if err != nil {
-		return nil, err
-	}
-	if _, err := out.Write(sizeBuf); err != nil {
-		out.Close()
-		return nil, err
-	}
-	if _, err := out.Write(headerBuf); err != nil {
-		out.Close()
-		return nil, err
+	if _, err := w.Write(sizeBuf); err != nil {
+		return err
 	}
-	return out, nil
+	_, err = w.Write(headerBuf)
+	return err
 }
 
-// createSymlink 在 .unpacked 中创建符号链接
-func createSymlink(dest, filepathRel, link string) error {
-	base := dest + ".unpacked"
+// createSymlink 在 .unpacked 目录 base 中创建符号链接
+func createSymlink(base, filepathRel, link string) error {
 	if err := os.MkdirAll(filepath.Join(base, filepath.Dir(filepathRel)), 0o755); err != nil {
 		return err
 	}
@@ -155,38 +361,147 @@ func createSymlink(dest, filepathRel, link string) error {
 
 // --------- 头解析 ---------
 
-func decodeHeader(bs []byte) (FilesystemEntry, error) {
-	var m map[string]any
-	if err := json.Unmarshal(bs, &m); err != nil {
+// maxDeclaredSize 头中文件大小的最大可表示值（JSON 数字可精确表示的最大整数）
+const maxDeclaredSize = 1<<53 - 1
+
+// maxNestedEntries 不限制 MaxDepth 时允许的最大嵌套层数；每层条目占两层 JSON 对象，
+// 取值须使该限制先于 encoding/json 的嵌套上限（10000）生效
+const maxNestedEntries = 4000
+
+// decodeHeader 流式解析头 JSON，在构建条目的同时检查数量与深度限制，
+// 超出限制的头不会被完整载入内存
+func decodeHeader(bs []byte, limits *headerLimits) (FilesystemEntry, error) {
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	if tok, err := dec.Token(); err != nil {
+		return nil, corruptHeader("invalid header JSON", err)
+	} else if tok != json.Delim('{') {
+		return nil, corruptHeader("root is not a directory", nil)
+	}
+	root, err := decodeEntry(dec, 0, limits)
+	if err != nil {
+		if errors.Is(err, ErrLimitExceeded) || errors.Is(err, ErrCorruptHeader) {
+			return nil, err
+		}
+		return nil, corruptHeader("invalid header JSON", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, corruptHeader("invalid header JSON", errors.New("unexpected data after header"))
+	}
+	if _, ok := root.(*FilesystemDirectoryEntry); !ok {
+		return nil, corruptHeader("root is not a directory", nil)
+	}
+	return root, nil
+}
+
+// decodeEntry 读取 '{' 之后的条目对象，depth 为条目所在层级；
+// "files" 对象逐个子条目解析，其余字段交给 parseEntry
+func decodeEntry(dec *json.Decoder, depth int, limits *headerLimits) (FilesystemEntry, error) {
+	m := map[string]any{}
+	var files map[string]FilesystemEntry
+	isDir := false
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		if key != "files" {
+			var v any
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+		isDir = true
+		if files, err = decodeFiles(dec, depth, limits); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
 		return nil, err
 	}
-	return parseEntry(m)
+	if !isDir {
+		return parseEntry(m, limits)
+	}
+	if files == nil && depth == 0 {
+		return nil, corruptHeader("root is not a directory", nil)
+	}
+	dir := &FilesystemDirectoryEntry{Files: files}
+	if dir.Files == nil {
+		dir.Files = map[string]FilesystemEntry{}
+	}
+	if u, ok := m["unpacked"].(bool); ok {
+		dir.Unpacked = u
+	}
+	return dir, nil
 }
 
-func parseEntry(m map[string]any) (FilesystemEntry, error) {
-	// 目录
-	if files, ok := m["files"]; ok {
-		dir := &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{}}
-		if u, ok := m["unpacked"].(bool); ok {
-			dir.Unpacked = u
+// decodeFiles 读取目录的 "files" 值；不是对象时返回 nil，其中不是对象的子项被忽略
+func decodeFiles(dec *json.Decoder, depth int, limits *headerLimits) (map[string]FilesystemEntry, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return nil, skipValue(dec, tok)
+	}
+	files := map[string]FilesystemEntry{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
 		}
-		fm, ok := files.(map[string]any)
-		if !ok {
-			return dir, nil
+		name, _ := tok.(string)
+		if tok, err = dec.Token(); err != nil {
+			return nil, err
 		}
-		for name, child := range fm {
-			childMap, ok := child.(map[string]any)
-			if !ok {
-				continue
-			}
-			ce, err := parseEntry(childMap)
-			if err != nil {
+		if tok != json.Delim('{') {
+			if err := skipValue(dec, tok); err != nil {
 				return nil, err
 			}
-			dir.Files[name] = ce
+			continue
 		}
-		return dir, nil
+		if err := limits.enter(depth + 1); err != nil {
+			return nil, err
+		}
+		if depth+1 > maxNestedEntries {
+			return nil, &LimitError{Limit: "depth", Value: int64(depth + 1), Max: maxNestedEntries}
+		}
+		child, err := decodeEntry(dec, depth+1, limits)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = child
 	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// skipValue 跳过以 tok 开头的 JSON 值
+func skipValue(dec *json.Decoder, tok json.Token) error {
+	if tok != json.Delim('[') && tok != json.Delim('{') {
+		return nil
+	}
+	for open := 1; open > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('['), json.Delim('{'):
+			open++
+		case json.Delim(']'), json.Delim('}'):
+			open--
+		}
+	}
+	return nil
+}
+
+// parseEntry 将不含 "files" 的 JSON 对象转换为链接或文件条目，并检查总大小限制
+func parseEntry(m map[string]any, limits *headerLimits) (FilesystemEntry, error) {
 	// 链接
 	if link, ok := m["link"].(string); ok {
 		l := &FilesystemLinkEntry{Link: link}
@@ -207,8 +522,27 @@ func parseEntry(m map[string]any) (FilesystemEntry, error) {
 		f.Offset = off
 	}
 	if sz, ok := m["size"].(float64); ok {
+		if sz > float64(maxDeclaredSize) {
+			sz = -1 // 超出表示范围的大小视为不合法，由校验丢弃
+		}
 		f.Size = int(sz)
 	}
+	if c, ok := m["compression"].(map[string]any); ok {
+		f.Compression = &FileCompression{}
+		if alg, ok := c["algorithm"].(string); ok {
+			f.Compression.Algorithm = alg
+		}
+		if sz, ok := c["size"].(float64); ok {
+			if sz > float64(maxDeclaredSize) {
+				sz = -1
+			}
+			f.Compression.Size = int(sz)
+		}
+	}
+	// 压缩存储的文件按解压后的大小计入总大小限制
+	if err := limits.addSize(contentSize(f)); err != nil {
+		return nil, err
+	}
 	if integ, ok := m["integrity"].(map[string]any); ok {
 		var fi FileIntegrity
 		if alg, ok := integ["algorithm"].(string); ok {
@@ -0,0 +1,101 @@
+package asar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// snapshotDir 返回目录中全部路径及文件内容
+func snapshotDir(t testing.TB, dir string) map[string]string {
+	t.Helper()
+	snap := map[string]string{}
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if d.Type().IsRegular() {
+			bs, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			snap[rel] = string(bs)
+		} else {
+			snap[rel] = d.Type().String()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snap
+}
+
+// existingDest 在新目录中放置已有的归档与 .unpacked 目录，返回归档路径
+func existingDest(t testing.TB) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"app.asar": "old archive", "app.asar.unpacked/old.txt": "old"})
+	return filepath.Join(dir, "app.asar")
+}
+
+func TestPackFailureLeavesDestUnchanged(t *testing.T) {
+	src := testTree(t)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name string
+		pack func(dest string) error
+		want error
+	}{
+		{"cancelled", func(dest string) error {
+			return CreatePackageWithOptions(src, dest, CreateOptions{Dot: true, UnpackDir: "assets", Context: cancelled})
+		}, context.Canceled},
+		{"source changed", func(dest string) error {
+			fsys := os.DirFS(src)
+			options := CreateOptions{Dot: true, UnpackDir: "assets", Pattern: "/**/*"}
+			names, meta, err := CrawlFSWithOptions(fsys, options)
+			if err != nil {
+				return err
+			}
+			// 遍历之后、写入之前文件变大
+			writeTree(t, src, map[string]string{"dir/b.txt": strings.Repeat("grown", 100)})
+			err = CreatePackageFromFSFiles(fsys, dest, names, meta, options)
+			if err != nil && !strings.Contains(err.Error(), "size changed") {
+				t.Errorf("source changed: unexpected error %v", err)
+			}
+			return err
+		}, nil},
+	}
+	for _, tt := range tests {
+		dest := existingDest(t)
+		before := snapshotDir(t, filepath.Dir(dest))
+		err := tt.pack(dest)
+		if err == nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Fatalf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+		if after := snapshotDir(t, filepath.Dir(dest)); !reflect.DeepEqual(after, before) {
+			t.Errorf("%s: dest directory changed:\n%v\nwant\n%v", tt.name, after, before)
+		}
+	}
+}
+
+func TestPackRemovesStaleUnpacked(t *testing.T) {
+	src := testTree(t)
+	dest := existingDest(t)
+	if err := CreatePackageWithOptions(src, dest, CreateOptions{Dot: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dest + ".unpacked"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("stale .unpacked left next to the new archive: %v", err)
+	}
+	if bs, _ := os.ReadFile(dest); bytes.Equal(bs, []byte("old archive")) {
+		t.Fatal("archive was not replaced")
+	}
+}
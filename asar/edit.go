@@ -0,0 +1,328 @@
+package asar
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+)
+
+// Editor 修改已有归档：添加、替换或删除条目，无需解包重打。
+// Commit 时将整个归档重写到临时文件：写出新头，原有数据区按原样复制（偏移保持不变），新内容追加在其后，
+// 因此每次 Commit 的开销与归档大小成正比；被替换或删除的文件留下的无用字节可通过 Compact 回收
+type Editor struct {
+	archivePath string
+	root        *FilesystemDirectoryEntry
+	headerSize  int
+	dataSize    int64
+
+	spool   transformSpool
+	pending map[*FilesystemFileEntry]pendingFile
+	closed  bool
+}
+
+// pendingFile 尚未提交的文件内容及 AddFile 时给出的权限
+type pendingFile struct {
+	sec  *io.SectionReader
+	mode fs.FileMode
+}
+
+// Edit 读取归档头并返回 Editor；头中存在不合法条目时拒绝编辑，以免静默丢弃条目。
+// 修改在 Commit 之前不会写入磁盘，使用完毕后需调用 Close 释放暂存数据
+func Edit(archivePath string) (*Editor, error) {
+	abs, err := filepath.Abs(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	fsys, err := loadFilesystem(abs, ReadOptions{Strict: true})
+	if err != nil {
+		return nil, err
+	}
+	st, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	e := &Editor{
+		archivePath: abs,
+		root:        fsys.GetHeader().(*FilesystemDirectoryEntry),
+		headerSize:  fsys.GetHeaderSize(),
+		dataSize:    st.Size() - 8 - int64(fsys.GetHeaderSize()),
+		pending:     map[*FilesystemFileEntry]pendingFile{},
+	}
+	return e, nil
+}
+
+// AddFile 添加文件或替换已有的文件与链接，内容读取自 r。
+// 被替换的文件保持原有的 unpacked 状态，新文件继承所在目录的 unpacked 状态
+func (e *Editor) AddFile(name string, r io.Reader, mode fs.FileMode) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	dir, base, err := entryParent(e.root, name)
+	if err != nil {
+		return &PathError{Op: "add", Archive: e.archivePath, Path: name, Err: err}
+	}
+	if base == "" {
+		return &PathError{Op: "add", Archive: e.archivePath, Path: name, Err: errors.New("invalid entry name")}
+	}
+	unpacked := dir.Unpacked
+	switch old := dir.Files[base].(type) {
+	case *FilesystemDirectoryEntry:
+		return &PathError{Op: "add", Archive: e.archivePath, Path: name, Err: errors.New("is a directory")}
+	case *FilesystemFileEntry:
+		unpacked = old.Unpacked
+		delete(e.pending, old)
+	}
+	// 暂存内容的同时计算完整性，r 只读取一次
+	sec, integ, err := e.spool.addWithIntegrity(io.NopCloser(r))
+	if err != nil {
+		return &PathError{Op: "add", Archive: e.archivePath, Path: name, Err: err}
+	}
+	if sec.Size() > maxFileSize {
+		return &PathError{Op: "add", Archive: e.archivePath, Path: name, Err: ErrFileTooLarge}
+	}
+	fe := &FilesystemFileEntry{
+		Unpacked:   unpacked,
+		Executable: executableMode(mode),
+		Size:       int(sec.Size()),
+		Integrity:  integ,
+	}
+	dir.Files[base] = fe
+	e.pending[fe] = pendingFile{sec: sec, mode: mode.Perm()}
+	return nil
+}
+
+// Mkdir 添加目录，缺失的上级目录会自动创建；目录已存在时不报错
+func (e *Editor) Mkdir(name string) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	dir, base, err := entryParent(e.root, name)
+	if err != nil {
+		return &PathError{Op: "mkdir", Archive: e.archivePath, Path: name, Err: err}
+	}
+	if base == "" {
+		return nil
+	}
+	switch dir.Files[base].(type) {
+	case nil:
+		dir.Files[base] = &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{}, EntryMetadata: EntryMetadata{Unpacked: dir.Unpacked}}
+	case *FilesystemDirectoryEntry:
+	default:
+		return &PathError{Op: "mkdir", Archive: e.archivePath, Path: name, Err: fs.ErrExist}
+	}
+	return nil
+}
+
+// Symlink 添加或替换符号链接，target 与 os.Symlink 一样相对于链接所在目录，且不能指向归档之外
+func (e *Editor) Symlink(name, target string) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	dir, base, err := entryParent(e.root, name)
+	if err != nil {
+		return &PathError{Op: "symlink", Archive: e.archivePath, Path: name, Err: err}
+	}
+	if base == "" {
+		return &PathError{Op: "symlink", Archive: e.archivePath, Path: name, Err: errors.New("invalid entry name")}
+	}
+	link := path.Join(path.Dir(cleanEntryName(name)), target)
+	if path.IsAbs(target) || hasParentOutOf(link) {
+		return &PathError{Op: "symlink", Archive: e.archivePath, Path: name, Err: linkEscapes(target)}
+	}
+	switch old := dir.Files[base].(type) {
+	case *FilesystemDirectoryEntry:
+		return &PathError{Op: "symlink", Archive: e.archivePath, Path: name, Err: errors.New("is a directory")}
+	case *FilesystemFileEntry:
+		delete(e.pending, old)
+	}
+	dir.Files[base] = &FilesystemLinkEntry{Link: link, EntryMetadata: EntryMetadata{Unpacked: dir.Unpacked}}
+	return nil
+}
+
+// Remove 删除文件、链接或目录（连同其内容）
+func (e *Editor) Remove(name string) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	clean := cleanEntryName(name)
+	parent, _, err := e.findParent(clean)
+	if err != nil || clean == "." {
+		return &PathError{Op: "remove", Archive: e.archivePath, Path: name, Err: ErrNotFound}
+	}
+	base := path.Base(clean)
+	entry, ok := parent.Files[base]
+	if !ok {
+		return &PathError{Op: "remove", Archive: e.archivePath, Path: name, Err: ErrNotFound}
+	}
+	delete(parent.Files, base)
+	if fe, ok := entry.(*FilesystemFileEntry); ok {
+		delete(e.pending, fe)
+	}
+	walkEntries(entry, func(p string, child FilesystemEntry) error {
+		if fe, ok := child.(*FilesystemFileEntry); ok {
+			delete(e.pending, fe)
+		}
+		return nil
+	})
+	return nil
+}
+
+// findParent 在不创建目录的前提下返回 clean 的上级目录
+func (e *Editor) findParent(clean string) (*FilesystemDirectoryEntry, string, error) {
+	dir := path.Dir(clean)
+	if dir == "." {
+		return e.root, "", nil
+	}
+	fsys := &Filesystem{header: e.root}
+	node, real, err := fsys.findNode(dir, true)
+	if err != nil {
+		return nil, "", err
+	}
+	d, ok := node.(*FilesystemDirectoryEntry)
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+	return d, real, nil
+}
+
+// Commit 将修改写回归档：重写整个归档文件，原有数据区原样复制，新内容追加在其后，.unpacked 目录同步更新；
+// 写入过程与打包一样先写临时文件再重命名，失败时原归档保持不变。Commit 之后 Editor 不可再使用
+func (e *Editor) Commit() error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer e.Close()
+
+	// 为追加的打包文件按路径顺序分配偏移，同时收集 unpacked 条目
+	type unpackedEntry struct {
+		name  string
+		entry FilesystemEntry
+	}
+	var appended []*FilesystemFileEntry
+	var unpacked []unpackedEntry
+	offset := e.dataSize
+	walkEntries(e.root, func(p string, entry FilesystemEntry) error {
+		switch t := entry.(type) {
+		case *FilesystemFileEntry:
+			if t.Unpacked {
+				unpacked = append(unpacked, unpackedEntry{p, t})
+			} else if pf, ok := e.pending[t]; ok {
+				t.Offset = strconv.FormatInt(offset, 10)
+				offset += pf.sec.Size()
+				appended = append(appended, t)
+			}
+		case *FilesystemLinkEntry:
+			if t.Unpacked {
+				unpacked = append(unpacked, unpackedEntry{p, t})
+			}
+		}
+		return nil
+	})
+
+	output, err := newAtomicOutput(e.archivePath, len(unpacked) > 0)
+	if err != nil {
+		return err
+	}
+	defer output.cleanup()
+	if err := writeFilesystemHeader(output.file, e.root); err != nil {
+		return err
+	}
+	src, err := os.Open(e.archivePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if _, err := copyBuffer(output.file, io.NewSectionReader(src, int64(8+e.headerSize), e.dataSize)); err != nil {
+		return err
+	}
+	for _, fe := range appended {
+		sec := e.pending[fe].sec
+		if _, err := copyBuffer(output.file, io.NewSectionReader(sec, 0, sec.Size())); err != nil {
+			return err
+		}
+	}
+	for _, u := range unpacked {
+		if err := e.writeUnpacked(output.unpacked, u.name, u.entry); err != nil {
+			return &PathError{Op: "commit", Archive: e.archivePath, Path: u.name, Err: err}
+		}
+	}
+	return output.commit()
+}
+
+// writeUnpacked 在新的 .unpacked 目录中写出条目，新内容来自暂存数据
+func (e *Editor) writeUnpacked(base, name string, entry FilesystemEntry) error {
+	if fe, ok := entry.(*FilesystemFileEntry); ok {
+		if pf, ok := e.pending[fe]; ok {
+			return copyUnpackedEntry(e.archivePath, base, name, entry, io.NewSectionReader(pf.sec, 0, pf.sec.Size()), pf.mode)
+		}
+	}
+	return copyUnpackedEntry(e.archivePath, base, name, entry, nil, 0)
+}
+
+// copyUnpackedEntry 在 .unpacked 目录 base 中写出 unpacked 条目：链接按头中的目标重建，
+// 文件内容取自 content，content 为 nil 时从 archivePath 对应的 .unpacked 目录复制。
+// mode 为 0 时沿用来源文件的权限，来源不是文件时按头中的可执行位取 0644 或 0755
+func copyUnpackedEntry(archivePath, base, name string, entry FilesystemEntry, content io.Reader, mode fs.FileMode) error {
+	switch t := entry.(type) {
+	case *FilesystemLinkEntry:
+		return createSymlink(base, filepath.FromSlash(name), filepath.FromSlash(relPath(path.Dir(name), t.Link)))
+	case *FilesystemFileEntry:
+		if content == nil {
+			p, err := unpackedPath(archivePath, name)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			content = f
+		}
+		if mode == 0 {
+			mode = unpackedMode(t, content)
+		}
+		out, err := createUnpacked(name, base, mode)
+		if err != nil {
+			return err
+		}
+		if _, err := copyBuffer(out, content); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+	return nil
+}
+
+// unpackedMode 返回写出 unpacked 文件时使用的权限：content 可 Stat 时取其权限，否则按可执行位决定
+func unpackedMode(f *FilesystemFileEntry, content io.Reader) fs.FileMode {
+	if st, ok := content.(interface{ Stat() (fs.FileInfo, error) }); ok {
+		if info, err := st.Stat(); err == nil {
+			return info.Mode().Perm()
+		}
+	}
+	if f.Executable {
+		return 0o755
+	}
+	return 0o644
+}
+
+// Close 放弃未提交的修改并删除暂存数据，可重复调用
+func (e *Editor) Close() error {
+	e.closed = true
+	e.spool.close()
+	e.pending = nil
+	return nil
+}
+
+func (e *Editor) check() error {
+	if e.closed {
+		return errors.New("asar: editor already closed")
+	}
+	return nil
+}
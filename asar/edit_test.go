@@ -0,0 +1,88 @@
+package asar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEditRoundTrip(t *testing.T) {
+	src := testTree(t)
+	archive := packTree(t, src, CreateOptions{UnpackDir: "assets"})
+	// 编辑后 .unpacked 中已有文件的权限保持不变
+	img := filepath.Join(archive+".unpacked", "assets", "img.png")
+	if err := os.Chmod(img, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	e, err := Edit(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	steps := []error{
+		e.AddFile("dir/b.txt", strings.NewReader("replaced"), 0o644),
+		e.AddFile("new/c.txt", strings.NewReader("new file"), 0o644),
+		e.AddFile("assets/run.sh", strings.NewReader("#!/bin/sh\n"), 0o750),
+		e.Symlink("new/link", "c.txt"),
+		e.Remove("a.txt"),
+	}
+	for _, err := range steps {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := loadFilesystem(archive, ReadOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"dir/b.txt": "replaced", "new/c.txt": "new file", "new/link": "new file", "assets/run.sh": "#!/bin/sh\n", "dir/run.sh": "#!/bin/sh\necho hi\n"} {
+		e, err := fsys.GetFile(name, true)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		f := e.(*FilesystemFileEntry)
+		got, err := ReadFileSync(fsys, name, f)
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", name, got, err, want)
+		}
+		integ, _ := GetFileIntegrity(strings.NewReader(want))
+		if f.Integrity.Hash != integ.Hash {
+			t.Errorf("%s: integrity does not match content", name)
+		}
+	}
+	if _, err := fsys.GetFile("a.txt", false); err == nil {
+		t.Error("a.txt was not removed")
+	}
+	for name, want := range map[string]os.FileMode{"assets/img.png": 0o600, "assets/run.sh": 0o750} {
+		info, err := os.Stat(filepath.Join(archive+".unpacked", filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s: mode %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestEditRemovesEmptyUnpacked(t *testing.T) {
+	archive := packTree(t, testTree(t), CreateOptions{UnpackDir: "assets"})
+	e, err := Edit(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	if err := e.Remove("assets"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(archive + ".unpacked"); !os.IsNotExist(err) {
+		t.Fatalf(".unpacked left after removing every unpacked entry: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package asar
+
+import (
+	"errors"
+)
+
+// 包内各函数返回的错误均可通过 errors.Is 与以下哨兵错误比较
+var (
+	// ErrNotFound 归档内不存在指定条目
+	ErrNotFound = errors.New("not found in archive")
+	// ErrNotFile 条目不是普通文件（目录或未解析的链接）
+	ErrNotFile = errors.New("not a file")
+	// ErrLinkEscapes 符号链接或路径指向归档（或目标目录）之外
+	ErrLinkEscapes = errors.New("links out of the package")
+	// ErrCorruptHeader 归档头损坏或包含不合法的条目
+	ErrCorruptHeader = errors.New("corrupt archive header")
+	// ErrFileTooLarge 单个文件超过 ASAR 可记录的 4.2GB 上限
+	ErrFileTooLarge = errors.New("file size can not be larger than 4.2GB")
+	// ErrPatternTooLarge glob 模式经花括号展开后的模式数超过上限
+	ErrPatternTooLarge = errors.New("glob pattern expands to too many alternatives")
+	// ErrPatchMismatch 补丁与旧归档不匹配，或补丁损坏导致校验失败
+	ErrPatchMismatch = errors.New("patch does not match archive")
+)
+
+// errTooManyLinks 解析符号链接超过 maxLinkHops 跳
+var errTooManyLinks = errors.New("too many levels of symbolic links")
+
+// PathError 记录出错的操作、归档路径与条目路径，Err 为哨兵错误或底层的系统错误
+type PathError struct {
+	Op      string
+	Archive string // 归档文件路径，未知时为空
+	Path    string // 归档内的条目路径
+	Err     error
+}
+
+func (e *PathError) Error() string {
+	p := e.Path
+	if e.Archive != "" {
+		p = e.Archive + ":" + p
+	}
+	return e.Op + " " + p + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error { return e.Err }
+
+// pathError 将 err 包装为 *PathError；err 已是 *PathError 时原样返回，避免重复包装
+func pathError(op, archive, p string, err error) error {
+	if _, ok := err.(*PathError); ok {
+		return err
+	}
+	return &PathError{Op: op, Archive: archive, Path: p, Err: err}
+}
+
+// kindError 带有完整描述的错误，errors.Is 可同时匹配其类别与底层原因
+type kindError struct {
+	msg   string
+	kind  error
+	cause error
+}
+
+func (e *kindError) Error() string { return e.msg }
+
+func (e *kindError) Unwrap() []error {
+	if e.cause == nil {
+		return []error{e.kind}
+	}
+	return []error{e.kind, e.cause}
+}
+
+// corruptHeader 返回 ErrCorruptHeader 类别的错误，cause 不为 nil 时附加在描述之后并可被 errors.Is 匹配
+func corruptHeader(msg string, cause error) error {
+	msg = ErrCorruptHeader.Error() + ": " + msg
+	if cause != nil {
+		msg += ": " + cause.Error()
+	}
+	return &kindError{msg: msg, kind: ErrCorruptHeader, cause: cause}
+}
+
+// linkEscapes 返回 ErrLinkEscapes 类别的错误，描述中包含越界的目标
+func linkEscapes(target string) error {
+	return &kindError{msg: "file \"" + target + "\" " + ErrLinkEscapes.Error(), kind: ErrLinkEscapes}
+}
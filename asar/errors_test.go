@@ -0,0 +1,75 @@
+package asar
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// hugeFileInfo 大小超过 ASAR 上限的文件信息
+type hugeFileInfo struct{}
+
+func (hugeFileInfo) Name() string       { return "huge" }
+func (hugeFileInfo) Size() int64        { return 5 << 30 }
+func (hugeFileInfo) Mode() fs.FileMode  { return 0o644 }
+func (hugeFileInfo) ModTime() time.Time { return time.Time{} }
+func (hugeFileInfo) IsDir() bool        { return false }
+func (hugeFileInfo) Sys() any           { return nil }
+
+func TestErrors(t *testing.T) {
+	src := testTree(t)
+	archive := packTree(t, src, CreateOptions{})
+	fsys, err := loadFilesystem(archive, ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenReader(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	corrupt := filepath.Join(t.TempDir(), "corrupt.asar")
+	if err := os.WriteFile(corrupt, rawArchive(`{"files":`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		err  func() error
+		want error
+		path string // 非空时要求 *PathError 的 Path
+	}{
+		{"GetFile missing", func() error { _, err := fsys.GetFile("dir/missing", true); return err }, ErrNotFound, "dir/missing"},
+		{"ExtractFile missing", func() error { _, err := ExtractFile(archive, "missing", true); return err }, ErrNotFound, "missing"},
+		{"ExtractFile directory", func() error { _, err := ExtractFile(archive, "dir", true); return err }, ErrNotFile, "dir"},
+		{"ExtractFile corrupt", func() error { _, err := ExtractFile(corrupt, "a.txt", true); return err }, ErrCorruptHeader, ""},
+		{"InsertLink escapes", func() error {
+			_, err := NewFilesystem(src).InsertLink(filepath.Join(src, "l"), false, src, "../outside", src)
+			return err
+		}, ErrLinkEscapes, filepath.Join(src, "l")},
+		{"InsertFile too large", func() error {
+			return NewFilesystem(src).InsertFile(filepath.Join(src, "huge"), nil, false, hugeFileInfo{})
+		}, ErrFileTooLarge, filepath.Join(src, "huge")},
+		{"ExtractAll corrupt", func() error { return ExtractAll(corrupt, t.TempDir()) }, ErrCorruptHeader, ""},
+		{"ExtractAll missing archive", func() error { return ExtractAll(archive+".missing", t.TempDir()) }, fs.ErrNotExist, ""},
+		{"Reader.OpenFile missing", func() error { _, err := r.OpenFile("missing"); return err }, ErrNotFound, "missing"},
+		{"Reader.OpenFile directory", func() error { _, err := r.OpenFile("dir"); return err }, ErrNotFile, "dir"},
+	}
+	for _, tt := range tests {
+		err := tt.err()
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		if tt.path == "" {
+			continue
+		}
+		var pe *PathError
+		if !errors.As(err, &pe) || pe.Path != tt.path {
+			t.Errorf("%s: err = %#v, want *PathError for %q", tt.name, err, tt.path)
+		}
+	}
+}
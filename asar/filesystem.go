@@ -3,11 +3,17 @@ package asar
 import (
 	"errors"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 )
 
+// maxLinkHops 解析符号链接的最大跳数，防止链接成环
+const maxLinkHops = 40
+
 // EntryMetadata 表示通用条目元数据
 type EntryMetadata struct {
 	Unpacked bool `json:"unpacked,omitempty"`
@@ -26,9 +32,17 @@ type FilesystemFileEntry struct {
 	Offset     string        `json:"offset"`
 	Size       int           `json:"size"`
 	Integrity  FileIntegrity `json:"integrity"`
+	// Compression 非 nil 时文件以压缩形式存储：Size 与 Offset 描述压缩后的数据，Integrity 按原始内容计算
+	Compression *FileCompression `json:"compression,omitempty"`
 	EntryMetadata
 }
 
+// FileCompression 压缩存储的文件的编码方式与原始大小
+type FileCompression struct {
+	Algorithm string `json:"algorithm"`
+	Size      int    `json:"size"`
+}
+
 // FilesystemLinkEntry 符号链接条目
 type FilesystemLinkEntry struct {
 	Link string `json:"link"`
@@ -44,6 +58,7 @@ type Filesystem struct {
 	header     FilesystemEntry
 	headerSize int
 	offset     int64
+	invalid    []*InvalidEntryError
 }
 
 // NewFilesystem 创建新的文件系统对象
@@ -63,6 +78,9 @@ func (fsys *Filesystem) GetRootPath() string { return fsys.src }
 // GetHeader 返回头对象
 func (fsys *Filesystem) GetHeader() FilesystemEntry { return fsys.header }
 
+// InvalidEntries 返回加载头时被丢弃的不合法条目
+func (fsys *Filesystem) InvalidEntries() []*InvalidEntryError { return fsys.invalid }
+
 // GetHeaderSize 返回头大小
 func (fsys *Filesystem) GetHeaderSize() int { return fsys.headerSize }
 
@@ -72,45 +90,47 @@ func (fsys *Filesystem) SetHeader(h FilesystemEntry, size int) {
 	fsys.headerSize = size
 }
 
-// searchNodeFromDirectory 按目录路径查找或创建节点
-func (fsys *Filesystem) searchNodeFromDirectory(p string) FilesystemEntry {
-	json := fsys.header
-	parts := filepath.SplitList(filepath.ToSlash(p))
-	// filepath.SplitList 不适用于一般路径分割，这里手动按 '/'
-	parts = splitPath(p)
-	for _, dir := range parts {
+// searchNodeFromDirectory 按目录路径查找或创建目录节点，路径中存在非目录条目时返回错误
+func (fsys *Filesystem) searchNodeFromDirectory(p string) (*FilesystemDirectoryEntry, error) {
+	cur, ok := fsys.header.(*FilesystemDirectoryEntry)
+	if !ok {
+		return nil, errors.New("archive root is not a directory")
+	}
+	for _, dir := range splitPath(p) {
 		if dir == "." || dir == "" {
 			continue
 		}
-		dirEntry, ok := json.(*FilesystemDirectoryEntry)
-		if !ok {
-			panic("unexpected directory state: " + p)
-		}
-		if dirEntry.Files == nil {
-			dirEntry.Files = map[string]FilesystemEntry{}
+		if cur.Files == nil {
+			cur.Files = map[string]FilesystemEntry{}
 		}
-		child, exists := dirEntry.Files[dir]
+		child, exists := cur.Files[dir]
 		if !exists {
 			child = &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{}}
-			dirEntry.Files[dir] = child
+			cur.Files[dir] = child
+		}
+		next, ok := child.(*FilesystemDirectoryEntry)
+		if !ok {
+			return nil, errors.New(p + ": \"" + dir + "\" is not a directory")
 		}
-		json = child
+		cur = next
+	}
+	if cur.Files == nil {
+		cur.Files = map[string]FilesystemEntry{}
 	}
-	return json
+	return cur, nil
 }
 
-// searchNodeFromPath 按完整路径查找或创建节点
+// searchNodeFromPath 按完整路径查找或创建节点，路径中存在非目录条目时返回 nil
 func (fsys *Filesystem) searchNodeFromPath(p string) FilesystemEntry {
 	rel, _ := filepath.Rel(fsys.src, p)
 	if rel == "." || rel == "" {
 		return fsys.header
 	}
-	name := filepath.Base(rel)
-	dir := filepath.Dir(rel)
-	node := fsys.searchNodeFromDirectory(dir).(*FilesystemDirectoryEntry)
-	if node.Files == nil {
-		node.Files = map[string]FilesystemEntry{}
+	node, err := fsys.searchNodeFromDirectory(filepath.Dir(rel))
+	if err != nil {
+		return nil
 	}
+	name := filepath.Base(rel)
 	child, ok := node.Files[name]
 	if !ok {
 		child = &FilesystemFileEntry{}
@@ -119,10 +139,13 @@ func (fsys *Filesystem) searchNodeFromPath(p string) FilesystemEntry {
 	return child
 }
 
-// InsertDirectory 插入目录节点，支持 unpack 标记
+// InsertDirectory 插入目录节点，支持 unpack 标记；上级路径中存在非目录条目时返回 nil
 func (fsys *Filesystem) InsertDirectory(p string, shouldUnpack bool) map[string]FilesystemEntry {
 	rel, _ := filepath.Rel(fsys.src, p)
-	parent := fsys.searchNodeFromDirectory(filepath.Dir(rel)).(*FilesystemDirectoryEntry)
+	parent, err := fsys.searchNodeFromDirectory(filepath.Dir(rel))
+	if err != nil {
+		return nil
+	}
 	name := filepath.Base(rel)
 	child, ok := parent.Files[name]
 	var node *FilesystemDirectoryEntry
@@ -149,15 +172,16 @@ func (fsys *Filesystem) InsertDirectory(p string, shouldUnpack bool) map[string]
 
 // InsertFile 插入文件节点并更新偏移、完整性等信息
 func (fsys *Filesystem) InsertFile(p string, streamGenerator func() (ioReadSeeker, error), shouldUnpack bool, fileStat os.FileInfo) error {
-	dirNode := fsys.searchNodeFromDirectory(filepath.Dir(p)).(*FilesystemDirectoryEntry)
-	// 确保文件节点类型为文件
 	rel, _ := filepath.Rel(fsys.src, p)
-	parent := fsys.searchNodeFromDirectory(filepath.Dir(rel)).(*FilesystemDirectoryEntry)
+	parent, err := fsys.searchNodeFromDirectory(filepath.Dir(rel))
+	if err != nil {
+		return err
+	}
 	name := filepath.Base(rel)
 	// 直接覆盖为文件条目，确保类型正确
 	node := &FilesystemFileEntry{}
 	parent.Files[name] = node
-	if shouldUnpack || dirNode.Unpacked {
+	if shouldUnpack || parent.Unpacked {
 		node.Size = int(fileStat.Size())
 		node.Unpacked = true
 		r, err := streamGenerator()
@@ -173,7 +197,7 @@ func (fsys *Filesystem) InsertFile(p string, streamGenerator func() (ioReadSeeke
 	}
 	size := int(fileStat.Size())
 	if uint64(size) > uint64(^uint32(0)) {
-		return errors.New(p + ": file size can not be larger than 4.2GB")
+		return &PathError{Op: "insert", Archive: fsys.src, Path: p, Err: ErrFileTooLarge}
 	}
 	node.Size = size
 	node.Offset = int64ToString(fsys.offset)
@@ -198,11 +222,16 @@ func (fsys *Filesystem) InsertFile(p string, streamGenerator func() (ioReadSeeke
 func (fsys *Filesystem) InsertLink(p string, shouldUnpack bool, parentPath string, symlink string, src string) (string, error) {
 	link := fsys.resolveLink(src, parentPath, symlink)
 	if hasParentOutOf(link) {
-		return "", errors.New(p + ": file \"" + link + "\" links out of the package")
+		return "", &PathError{Op: "insert", Archive: fsys.src, Path: p, Err: linkEscapes(link)}
 	}
-	node := fsys.searchNodeFromPath(p).(*FilesystemLinkEntry)
-	dirNode := fsys.searchNodeFromDirectory(filepath.Dir(p)).(*FilesystemDirectoryEntry)
-	if shouldUnpack || dirNode.Unpacked {
+	rel, _ := filepath.Rel(fsys.src, p)
+	parent, err := fsys.searchNodeFromDirectory(filepath.Dir(rel))
+	if err != nil {
+		return "", err
+	}
+	node := &FilesystemLinkEntry{}
+	parent.Files[filepath.Base(rel)] = node
+	if shouldUnpack || parent.Unpacked {
 		node.Unpacked = true
 	}
 	node.Link = link
@@ -236,28 +265,91 @@ func (fsys *Filesystem) ListFiles(isPack bool) []string {
 	return files
 }
 
-// GetNode 获取任意路径的条目（可解析符号链接）
-func (fsys *Filesystem) GetNode(p string, followLinks bool) FilesystemEntry {
-	node := fsys.searchNodeFromDirectory(filepath.Dir(p))
-	name := filepath.Base(p)
-	if lnk, ok := node.(*FilesystemLinkEntry); ok && followLinks {
-		return fsys.GetNode(filepath.Join(lnk.Link, name), followLinks)
+// walkEntries 按名称排序、深度优先遍历 root 下的全部条目，p 为不带前导 '/' 的相对路径
+func walkEntries(root FilesystemEntry, fn func(p string, e FilesystemEntry) error) error {
+	var walk func(base string, dir *FilesystemDirectoryEntry) error
+	walk = func(base string, dir *FilesystemDirectoryEntry) error {
+		names := make([]string, 0, len(dir.Files))
+		for name := range dir.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := dir.Files[name]
+			full := name
+			if base != "" {
+				full = base + "/" + name
+			}
+			if err := fn(full, child); err != nil {
+				return err
+			}
+			if d, ok := child.(*FilesystemDirectoryEntry); ok {
+				if err := walk(full, d); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if d, ok := root.(*FilesystemDirectoryEntry); ok {
+		return walk("", d)
 	}
-	if name != "" {
-		dir := node.(*FilesystemDirectoryEntry)
-		return dir.Files[name]
+	return nil
+}
+
+// GetNode 获取任意路径的条目（可解析符号链接），不存在时返回 nil；查找过程不会修改头，可并发调用
+func (fsys *Filesystem) GetNode(p string, followLinks bool) FilesystemEntry {
+	node, _, err := fsys.findNode(p, followLinks)
+	if err != nil {
+		return nil
 	}
 	return node
 }
 
-// GetFile 获取文件条目（可解析符号链接）
-func (fsys *Filesystem) GetFile(p string, followLinks bool) (FilesystemEntry, error) {
-	info := fsys.GetNode(p, followLinks)
-	if info == nil {
-		return nil, errors.New("\"" + p + "\" was not found in this archive")
+// findNode 只读地查找路径对应条目并返回解析后的真实路径；
+// 中间路径段上的符号链接总会被解析，末级链接仅在 followLinks 为 true 时解析
+func (fsys *Filesystem) findNode(p string, followLinks bool) (FilesystemEntry, string, error) {
+	parts := splitPath(p)
+	node := fsys.header
+	resolved := make([]string, 0, len(parts))
+	hops := 0
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		if part == "." {
+			continue
+		}
+		dir, ok := node.(*FilesystemDirectoryEntry)
+		if !ok {
+			return nil, "", ErrNotFound
+		}
+		child, ok := dir.Files[part]
+		if !ok || child == nil {
+			return nil, "", ErrNotFound
+		}
+		if lnk, ok := child.(*FilesystemLinkEntry); ok && (i < len(parts)-1 || followLinks) {
+			hops++
+			if hops > maxLinkHops {
+				return nil, "", errTooManyLinks
+			}
+			// 链接目标为相对于归档根目录的路径，从根目录重新解析
+			next := append(splitPath(lnk.Link), parts[i+1:]...)
+			parts = next
+			i = -1
+			node = fsys.header
+			resolved = resolved[:0]
+			continue
+		}
+		node = child
+		resolved = append(resolved, part)
 	}
-	if lnk, ok := info.(*FilesystemLinkEntry); ok && followLinks {
-		return fsys.GetFile(lnk.Link, followLinks)
+	return node, strings.Join(resolved, "/"), nil
+}
+
+// GetFile 获取文件条目（可解析符号链接），不存在时返回包装 ErrNotFound 的 *PathError
+func (fsys *Filesystem) GetFile(p string, followLinks bool) (FilesystemEntry, error) {
+	info, _, err := fsys.findNode(p, followLinks)
+	if err != nil {
+		return nil, &PathError{Op: "stat", Archive: fsys.src, Path: p, Err: err}
 	}
 	return info, nil
 }
@@ -302,8 +394,11 @@ func int64ToString(v int64) string { return fmtInt64(v) }
 
 func fmtInt64(v int64) string { return strconv.FormatInt(v, 10) }
 
-func isExecutable(fi os.FileInfo) bool {
-	return (fi.Mode()&0o100) != 0 && os.PathSeparator == '/'
+func isExecutable(fi os.FileInfo) bool { return executableMode(fi.Mode()) }
+
+// executableMode 判断权限是否记为可执行：属主可执行且不在 Windows 上（Windows 没有可执行位）
+func executableMode(mode fs.FileMode) bool {
+	return mode&0o100 != 0 && !isWindows()
 }
 
 func hasUnpacked(e FilesystemEntry) bool {
@@ -0,0 +1,39 @@
+package asar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInsertEntries(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"dir/a.txt": "hello"})
+	fsys := NewFilesystem(src)
+	if files := fsys.InsertDirectory(filepath.Join(src, "dir"), false); files == nil {
+		t.Fatal("InsertDirectory returned nil")
+	}
+	p := filepath.Join(src, "dir", "a.txt")
+	info, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := func() (ioReadSeeker, error) { return os.Open(p) }
+	if err := fsys.InsertFile(p, open, false, info); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.InsertLink(filepath.Join(src, "dir", "l"), false, filepath.Join(src, "dir"), "a.txt", src); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := fsys.GetFile("dir/l", true); err != nil || e.(*FilesystemFileEntry).Size != 5 {
+		t.Fatalf("dir/l = %v, %v", e, err)
+	}
+	// 上级路径为文件时不再 panic
+	if files := fsys.InsertDirectory(filepath.Join(src, "dir", "a.txt", "x"), false); files != nil {
+		t.Fatal("InsertDirectory under a file returned a directory")
+	}
+	if err := fsys.InsertFile(filepath.Join(p, "x"), open, false, info); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Fatalf("InsertFile under a file: %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package asar
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+// FS 将 ASAR 归档包装为只读的 io/fs 文件系统，可直接交给 http.FS、fs.WalkDir、html/template 等使用
+type FS struct {
+	fsys    *Filesystem
+	dir     string // 当前视图在归档内的根目录，"." 表示归档根
+	modTime time.Time
+}
+
+var (
+	_ fs.FS         = (*FS)(nil)
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+	_ fs.SubFS      = (*FS)(nil)
+	_ ReadLinkFS    = (*FS)(nil)
+)
+
+// OpenFS 读取归档头并返回对应的 fs.FS 视图
+func OpenFS(archivePath string) (*FS, error) {
+	fsys, err := ReadFilesystemSync(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewFS(fsys), nil
+}
+
+// NewFS 基于已加载的 Filesystem 创建 fs.FS 视图，条目的修改时间取归档文件的修改时间
+func NewFS(fsys *Filesystem) *FS {
+	f := &FS{fsys: fsys, dir: "."}
+	if st, err := os.Stat(fsys.GetRootPath()); err == nil {
+		f.modTime = st.ModTime()
+	}
+	return f
+}
+
+// Open 打开文件或目录，符号链接会被解析
+func (f *FS) Open(name string) (fs.File, error) {
+	entry, real, err := f.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info := f.newFileInfo(path.Base(name), entry)
+	switch e := entry.(type) {
+	case *FilesystemDirectoryEntry:
+		return &fsDir{name: name, info: info, dir: e, fs: f}, nil
+	case *FilesystemFileEntry:
+		r, err := OpenFileSync(f.fsys, real, e)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &fsFile{info: info, FileReader: r}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+}
+
+// ReadDir 读取目录并按名称排序返回条目，符号链接条目不会被解析
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, _, err := f.resolve("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	dir, ok := entry.(*FilesystemDirectoryEntry)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return f.dirEntries(dir), nil
+}
+
+// ReadFile 读取文件完整内容
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	entry, real, err := f.resolve("readfile", name, true)
+	if err != nil {
+		return nil, err
+	}
+	fe, ok := entry.(*FilesystemFileEntry)
+	if !ok {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errors.New("is a directory")}
+	}
+	content, err := ReadFileSync(f.fsys, real, fe)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	return content, nil
+}
+
+// Stat 返回条目信息，符号链接会被解析
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	entry, _, err := f.resolve("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return f.newFileInfo(path.Base(name), entry), nil
+}
+
+// Lstat 返回条目信息，末级符号链接不会被解析
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	entry, _, err := f.resolve("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return f.newFileInfo(path.Base(name), entry), nil
+}
+
+// ReadLink 返回符号链接目标，与 os.Readlink 一样相对于链接所在目录
+func (f *FS) ReadLink(name string) (string, error) {
+	entry, real, err := f.resolve("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	lnk, ok := entry.(*FilesystemLinkEntry)
+	if !ok {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return relPath(path.Dir(real), lnk.Link), nil
+}
+
+// Sub 返回以 dir 为根的子视图
+func (f *FS) Sub(dir string) (fs.FS, error) {
+	entry, real, err := f.resolve("sub", dir, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := entry.(*FilesystemDirectoryEntry); !ok {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: errors.New("not a directory")}
+	}
+	if real == "" {
+		real = "."
+	}
+	return &FS{fsys: f.fsys, dir: real, modTime: f.modTime}, nil
+}
+
+// resolve 校验 fs 路径并在归档内查找条目
+func (f *FS) resolve(op, name string, followLinks bool) (FilesystemEntry, string, error) {
+	if !fs.ValidPath(name) {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	entry, real, err := f.fsys.findNode(path.Join(f.dir, name), followLinks)
+	if err != nil {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return entry, real, nil
+}
+
+func (f *FS) dirEntries(dir *FilesystemDirectoryEntry) []fs.DirEntry {
+	names := make([]string, 0, len(dir.Files))
+	for n := range dir.Files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	out := make([]fs.DirEntry, 0, len(names))
+	for _, n := range names {
+		out = append(out, fs.FileInfoToDirEntry(f.newFileInfo(n, dir.Files[n])))
+	}
+	return out
+}
+
+func (f *FS) newFileInfo(name string, entry FilesystemEntry) *fileInfo {
+	return &fileInfo{name: name, entry: entry, modTime: f.modTime}
+}
+
+// fileInfo 将归档条目适配为 fs.FileInfo
+type fileInfo struct {
+	name    string
+	entry   FilesystemEntry
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string { return fi.name }
+
+func (fi *fileInfo) Size() int64 {
+	switch e := fi.entry.(type) {
+	case *FilesystemFileEntry:
+		return contentSize(e)
+	case *FilesystemLinkEntry:
+		return int64(len(e.Link))
+	}
+	return 0
+}
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	switch e := fi.entry.(type) {
+	case *FilesystemDirectoryEntry:
+		return fs.ModeDir | 0o555
+	case *FilesystemLinkEntry:
+		return fs.ModeSymlink | 0o777
+	case *FilesystemFileEntry:
+		if e.Executable {
+			return 0o555
+		}
+	}
+	return 0o444
+}
+
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+
+func (fi *fileInfo) IsDir() bool { return fi.Mode().IsDir() }
+
+// Sys 返回底层的归档条目
+func (fi *fileInfo) Sys() any { return fi.entry }
+
+// fsFile 为已打开的普通文件，以流的方式读取并支持 Seek 与 ReadAt
+type fsFile struct {
+	FileReader
+	info *fileInfo
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+// fsDir 为已打开的目录，实现 fs.ReadDirFile
+type fsDir struct {
+	name    string
+	info    *fileInfo
+	dir     *FilesystemDirectoryEntry
+	fs      *FS
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *fsDir) Close() error { return nil }
+
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.entries == nil {
+		d.entries = d.fs.dirEntries(d.dir)
+	}
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
@@ -0,0 +1,32 @@
+package asar
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	archive := packTree(t, testTree(t), CreateOptions{UnpackDir: "assets", Compress: "*.js"})
+	fsys, err := OpenFS(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 确认夹具同时覆盖 .unpacked 中的文件与压缩存储的文件
+	if e, _ := fsys.fsys.GetFile("assets/img.png", false); !e.(*FilesystemFileEntry).Unpacked {
+		t.Fatal("assets/img.png is not unpacked")
+	}
+	if e, _ := fsys.fsys.GetFile("dir/sub/c.js", false); e.(*FilesystemFileEntry).Compression == nil {
+		t.Fatal("dir/sub/c.js is not compressed")
+	}
+	expected := []string{"a.txt", ".hidden", "dir/b.txt", "dir/run.sh", "dir/sub/c.js", "assets/img.png", "link.txt", "linkdir"}
+	if err := fstest.TestFS(fsys, expected...); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := fsys.Sub("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(sub, "b.txt", "run.sh", "sub/c.js"); err != nil {
+		t.Fatal(err)
+	}
+}
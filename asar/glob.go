@@ -0,0 +1,642 @@
+package asar
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GlobOptions glob 匹配选项，含义与 minimatch 的同名选项一致
+type GlobOptions struct {
+	// Dot 为 true 时通配符可匹配以 '.' 开头的路径段（"." 与 ".." 始终需要显式写出）
+	Dot bool
+	// MatchBase 为 true 时不含 '/' 的模式只与路径的 basename 匹配
+	MatchBase bool
+	// NoCase 忽略大小写
+	NoCase bool
+	// NoBrace 关闭 {a,b} 与 {1..3} 展开
+	NoBrace bool
+	// NoExt 关闭 +(a|b) 等 extglob
+	NoExt bool
+	// NoNegate 关闭前导 '!' 取反
+	NoNegate bool
+}
+
+// Glob 编译后的 glob 模式，与 node-asar 使用的 minimatch 语义兼容：
+// 支持 "**" globstar、{a,b} 与 {1..3} 展开、?(..) *(..) +(..) @(..) !(..) extglob、
+// [...] 字符集（含 [:alpha:] 等 POSIX 类）、前导 '!' 取反、'#' 注释以及 dot/matchBase 选项。
+// 模式的前导 '/' 表示相对路径的根目录
+type Glob struct {
+	set     [][]globSegment
+	negate  bool
+	comment bool
+	opts    GlobOptions
+}
+
+// maxBraceExpansion 单个模式经花括号展开后允许的最大模式数，防止 {1..1000000000} 之类的模式耗尽内存
+const maxBraceExpansion = 10000
+
+// CompileGlob 编译 glob 模式；与 minimatch 一样，不完整的语法（如未闭合的 '['）按字面量处理。
+// 花括号展开超过上限时返回的 Glob 不匹配任何路径，需要得到错误时使用 ParseGlob
+func CompileGlob(pattern string, opts GlobOptions) *Glob {
+	g, err := ParseGlob(pattern, opts)
+	if err != nil {
+		return &Glob{opts: opts}
+	}
+	return g
+}
+
+// ParseGlob 与 CompileGlob 相同，但花括号展开超过上限时返回 ErrPatternTooLarge
+func ParseGlob(pattern string, opts GlobOptions) (*Glob, error) {
+	g := &Glob{opts: opts}
+	if strings.HasPrefix(pattern, "#") {
+		g.comment = true
+		return g, nil
+	}
+	if !opts.NoNegate {
+		for strings.HasPrefix(pattern, "!") && !strings.HasPrefix(pattern, "!(") {
+			g.negate = !g.negate
+			pattern = pattern[1:]
+		}
+	}
+	expanded := []string{pattern}
+	if !opts.NoBrace {
+		var err error
+		if expanded, err = expandBraces(pattern); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range expanded {
+		g.set = append(g.set, compileGlobSegments(p, opts))
+	}
+	return g, nil
+}
+
+// MatchGlob 判断 slash 相对路径 name 是否匹配 pattern
+func MatchGlob(pattern, name string, opts GlobOptions) bool {
+	return CompileGlob(pattern, opts).Match(name)
+}
+
+// Match 判断 slash 相对路径 name 是否匹配
+func (g *Glob) Match(name string) bool {
+	if g.comment {
+		return false
+	}
+	segs := splitGlobName(name)
+	matched := false
+	for _, pat := range g.set {
+		target := segs
+		if g.opts.MatchBase && len(pat) == 1 && len(segs) > 0 {
+			target = segs[len(segs)-1:]
+		}
+		if matchGlobSegments(pat, target, g.opts) {
+			matched = true
+			break
+		}
+	}
+	return matched != g.negate
+}
+
+// ------------- 编译 -------------
+
+type globTokenKind int
+
+const (
+	globLiteral globTokenKind = iota
+	globAny
+	globStar
+	globClass
+	globExt
+)
+
+type globToken struct {
+	kind  globTokenKind
+	r     rune
+	class *globCharClass
+	ext   rune // '?' '*' '+' '@' '!'
+	alts  [][]globToken
+}
+
+type globSegment struct {
+	globstar bool
+	toks     []globToken
+}
+
+func compileGlobSegments(pattern string, opts GlobOptions) []globSegment {
+	pattern = strings.TrimPrefix(pattern, "/")
+	for strings.HasPrefix(pattern, "./") {
+		pattern = pattern[2:]
+	}
+	pattern = strings.TrimSuffix(pattern, "/")
+	out := make([]globSegment, 0)
+	if pattern == "" {
+		return out
+	}
+	for _, seg := range splitGlobPattern(pattern) {
+		if seg == "" {
+			continue
+		}
+		if seg == "**" {
+			// 连续的 "**" 等价于一个
+			if n := len(out); n > 0 && out[n-1].globstar {
+				continue
+			}
+			out = append(out, globSegment{globstar: true})
+			continue
+		}
+		toks, _ := parseGlobTokens([]rune(seg), 0, false, opts)
+		out = append(out, globSegment{toks: toks})
+	}
+	return out
+}
+
+// splitGlobPattern 按 '/' 切分模式，忽略字符集与 extglob 括号内的 '/'
+func splitGlobPattern(p string) []string {
+	out := make([]string, 0)
+	depth := 0
+	inClass := false
+	start := 0
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; {
+		case c == '\\':
+			i++
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			if strings.IndexByte(p[i+1:], ']') >= 0 {
+				inClass = true
+				if i+1 < len(p) && p[i+1] == ']' {
+					i++
+				}
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case c == '/' && depth == 0:
+			out = append(out, p[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, p[start:])
+}
+
+// parseGlobTokens 解析单个路径段；inExt 为 true 时遇到 '|' 或 ')' 停止
+func parseGlobTokens(s []rune, i int, inExt bool, opts GlobOptions) ([]globToken, int) {
+	toks := make([]globToken, 0)
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case inExt && (c == '|' || c == ')'):
+			return toks, i
+		case c == '\\' && i+1 < len(s):
+			toks = append(toks, globToken{kind: globLiteral, r: s[i+1]})
+			i += 2
+			continue
+		case !opts.NoExt && strings.ContainsRune("?*+@!", c) && i+1 < len(s) && s[i+1] == '(' && findExtClose(s, i+1) > 0:
+			tok := globToken{kind: globExt, ext: c}
+			j := i + 2
+			for {
+				alt, next := parseGlobTokens(s, j, true, opts)
+				tok.alts = append(tok.alts, alt)
+				if next >= len(s) || s[next] == ')' {
+					j = next + 1
+					break
+				}
+				j = next + 1
+			}
+			toks = append(toks, tok)
+			i = j
+			continue
+		case c == '*':
+			if n := len(toks); n == 0 || toks[n-1].kind != globStar {
+				toks = append(toks, globToken{kind: globStar})
+			}
+		case c == '?':
+			toks = append(toks, globToken{kind: globAny})
+		case c == '[':
+			if class, next, ok := parseGlobClass(s, i); ok {
+				toks = append(toks, globToken{kind: globClass, class: class})
+				i = next
+				continue
+			}
+			toks = append(toks, globToken{kind: globLiteral, r: c})
+		default:
+			toks = append(toks, globToken{kind: globLiteral, r: c})
+		}
+		i++
+	}
+	return toks, i
+}
+
+// findExtClose 返回与 s[open] 处 '(' 匹配的 ')' 位置，不存在时返回 -1
+func findExtClose(s []rune, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// globCharClass 字符集 [...]
+type globCharClass struct {
+	negate bool
+	ranges [][2]rune
+	named  []func(rune) bool
+}
+
+var posixClasses = map[string]func(rune) bool{
+	"alnum":  func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"alpha":  unicode.IsLetter,
+	"blank":  func(r rune) bool { return r == ' ' || r == '\t' },
+	"cntrl":  unicode.IsControl,
+	"digit":  unicode.IsDigit,
+	"graph":  func(r rune) bool { return unicode.IsGraphic(r) && !unicode.IsSpace(r) },
+	"lower":  unicode.IsLower,
+	"print":  unicode.IsPrint,
+	"punct":  unicode.IsPunct,
+	"space":  unicode.IsSpace,
+	"upper":  unicode.IsUpper,
+	"word":   func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"xdigit": func(r rune) bool { return strings.ContainsRune("0123456789abcdefABCDEF", r) },
+}
+
+// parseGlobClass 解析从 s[i]（'['）开始的字符集，返回字符集与结束后的位置
+func parseGlobClass(s []rune, i int) (*globCharClass, int, bool) {
+	class := &globCharClass{}
+	j := i + 1
+	if j < len(s) && (s[j] == '!' || s[j] == '^') {
+		class.negate = true
+		j++
+	}
+	first := true
+	for j < len(s) {
+		c := s[j]
+		if c == ']' && !first {
+			return class, j + 1, true
+		}
+		first = false
+		if c == '[' && j+1 < len(s) && s[j+1] == ':' {
+			rest := string(s[j+2:])
+			if end := strings.Index(rest, ":]"); end >= 0 {
+				name := rest[:end]
+				if fn, ok := posixClasses[name]; ok {
+					class.named = append(class.named, fn)
+					j += 2 + len([]rune(name)) + 2
+					continue
+				}
+			}
+		}
+		if c == '\\' && j+1 < len(s) {
+			j++
+			c = s[j]
+		}
+		lo, hi := c, c
+		if j+2 < len(s) && s[j+1] == '-' && s[j+2] != ']' {
+			hi = s[j+2]
+			if hi == '\\' && j+3 < len(s) {
+				hi = s[j+3]
+				j++
+			}
+			j += 2
+		}
+		class.ranges = append(class.ranges, [2]rune{lo, hi})
+		j++
+	}
+	return nil, i, false
+}
+
+func (c *globCharClass) match(r rune, nocase bool) bool {
+	in := c.contains(r)
+	if !in && nocase {
+		in = c.contains(unicode.ToLower(r)) || c.contains(unicode.ToUpper(r))
+	}
+	return in != c.negate
+}
+
+func (c *globCharClass) contains(r rune) bool {
+	for _, rg := range c.ranges {
+		if r >= rg[0] && r <= rg[1] {
+			return true
+		}
+	}
+	for _, fn := range c.named {
+		if fn(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// ------------- 花括号展开 -------------
+
+// expandBraces 按 bash/minimatch 规则展开 {a,b} 与 {1..3}、{a..e}、{0..10..2}；无法展开的花括号保持字面量。
+// 展开结果超过 maxBraceExpansion 个时返回 ErrPatternTooLarge
+func expandBraces(p string) ([]string, error) {
+	out := make([]string, 0, 1)
+	if err := expandBracesInto(p, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func expandBracesInto(p string, out *[]string) error {
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			i++
+		case '{':
+			end, commas := findBraceClose(p, i)
+			if end < 0 {
+				continue
+			}
+			pre, body, post := p[:i], p[i+1:end], p[end+1:]
+			var alts []string
+			if len(commas) > 0 {
+				last := i + 1
+				for _, c := range commas {
+					alts = append(alts, p[last:c])
+					last = c + 1
+				}
+				alts = append(alts, p[last:end])
+			} else if r, ok, err := expandBraceRange(body); err != nil {
+				return err
+			} else if ok {
+				alts = r
+			} else {
+				continue
+			}
+			for _, a := range alts {
+				if err := expandBracesInto(pre+a+post, out); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	if len(*out) >= maxBraceExpansion {
+		return ErrPatternTooLarge
+	}
+	*out = append(*out, p)
+	return nil
+}
+
+// findBraceClose 返回匹配的 '}' 位置以及顶层 ',' 的位置
+func findBraceClose(p string, open int) (int, []int) {
+	depth := 0
+	commas := make([]int, 0)
+	for i := open; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i, commas
+			}
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		}
+	}
+	return -1, nil
+}
+
+// expandBraceRange 展开数字或单字符序列，如 1..3、a..e、0..10..2；序列长度超过上限时返回 ErrPatternTooLarge
+func expandBraceRange(body string) ([]string, bool, error) {
+	parts := strings.Split(body, "..")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, false, nil
+	}
+	step := 1
+	if len(parts) == 3 {
+		n, err := strconv.Atoi(parts[2])
+		if err != nil || n == 0 || n == math.MinInt {
+			return nil, false, nil
+		}
+		if n < 0 {
+			n = -n
+		}
+		step = n
+	}
+	if a, errA := strconv.Atoi(parts[0]); errA == nil {
+		b, errB := strconv.Atoi(parts[1])
+		if errB != nil {
+			return nil, false, nil
+		}
+		if rangeLen(a, b, step) > maxBraceExpansion {
+			return nil, false, ErrPatternTooLarge
+		}
+		width := 0
+		if (len(parts[0]) > 1 && strings.TrimLeft(parts[0], "-")[0] == '0') || (len(parts[1]) > 1 && strings.TrimLeft(parts[1], "-")[0] == '0') {
+			width = max(len(parts[0]), len(parts[1]))
+		}
+		out := make([]string, 0)
+		for _, v := range rangeInts(a, b, step) {
+			s := strconv.Itoa(v)
+			for len(s) < width {
+				s = "0" + s
+			}
+			out = append(out, s)
+		}
+		return out, true, nil
+	}
+	ra, rb := []rune(parts[0]), []rune(parts[1])
+	if len(ra) != 1 || len(rb) != 1 {
+		return nil, false, nil
+	}
+	if rangeLen(int(ra[0]), int(rb[0]), step) > maxBraceExpansion {
+		return nil, false, ErrPatternTooLarge
+	}
+	out := make([]string, 0)
+	for _, v := range rangeInts(int(ra[0]), int(rb[0]), step) {
+		out = append(out, string(rune(v)))
+	}
+	return out, true, nil
+}
+
+// rangeLen 返回 rangeInts(a, b, step) 的元素个数，不实际分配
+func rangeLen(a, b, step int) uint64 {
+	var diff uint64
+	if a <= b {
+		diff = uint64(b) - uint64(a)
+	} else {
+		diff = uint64(a) - uint64(b)
+	}
+	return diff/uint64(step) + 1
+}
+
+func rangeInts(a, b, step int) []int {
+	// 按元素个数生成，避免 v += step 在边界附近溢出
+	n := rangeLen(a, b, step)
+	if a > b {
+		step = -step
+	}
+	out := make([]int, 0, n)
+	for k := uint64(0); k < n; k++ {
+		out = append(out, a+int(k)*step)
+	}
+	return out
+}
+
+// ------------- 匹配 -------------
+
+func splitGlobName(name string) []string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = strings.Trim(name, "/")
+	for strings.HasPrefix(name, "./") {
+		name = name[2:]
+	}
+	if name == "" || name == "." {
+		return []string{}
+	}
+	segs := make([]string, 0)
+	for _, s := range strings.Split(name, "/") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+	return segs
+}
+
+func matchGlobSegments(pat []globSegment, segs []string, opts GlobOptions) bool {
+	for len(pat) > 0 {
+		if pat[0].globstar {
+			// "**" 匹配零个或多个路径段，不穿过隐藏段（除非 Dot）
+			for i := 0; i <= len(segs); i++ {
+				if matchGlobSegments(pat[1:], segs[i:], opts) {
+					return true
+				}
+				if i < len(segs) && !globstarMayConsume(segs[i], opts) {
+					return false
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 || !matchGlobSegment(pat[0].toks, segs[0], opts) {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
+
+func globstarMayConsume(seg string, opts GlobOptions) bool {
+	if seg == "." || seg == ".." {
+		return false
+	}
+	return opts.Dot || !strings.HasPrefix(seg, ".")
+}
+
+func matchGlobSegment(toks []globToken, seg string, opts GlobOptions) bool {
+	explicitDot := len(toks) > 0 && toks[0].kind == globLiteral && toks[0].r == '.'
+	if (seg == "." || seg == "..") && !explicitDot {
+		return false
+	}
+	if !opts.Dot && strings.HasPrefix(seg, ".") && !explicitDot {
+		return false
+	}
+	return matchGlobTokens(toks, []rune(seg), opts)
+}
+
+func matchGlobTokens(toks []globToken, s []rune, opts GlobOptions) bool {
+	if len(toks) == 0 {
+		return len(s) == 0
+	}
+	t, rest := toks[0], toks[1:]
+	switch t.kind {
+	case globLiteral:
+		return len(s) > 0 && runeEqual(s[0], t.r, opts.NoCase) && matchGlobTokens(rest, s[1:], opts)
+	case globAny:
+		return len(s) > 0 && matchGlobTokens(rest, s[1:], opts)
+	case globClass:
+		return len(s) > 0 && t.class.match(s[0], opts.NoCase) && matchGlobTokens(rest, s[1:], opts)
+	case globStar:
+		if len(rest) == 0 {
+			return true
+		}
+		for i := 0; i <= len(s); i++ {
+			if matchGlobTokens(rest, s[i:], opts) {
+				return true
+			}
+		}
+		return false
+	case globExt:
+		return matchGlobExt(t, rest, s, opts)
+	}
+	return false
+}
+
+// matchGlobExt 匹配 extglob：@ 恰好一次，? 零或一次，* 零或多次，+ 一或多次，! 不匹配任一分支
+func matchGlobExt(t globToken, rest []globToken, s []rune, opts GlobOptions) bool {
+	anyAlt := func(part []rune) bool {
+		for _, alt := range t.alts {
+			if matchGlobTokens(alt, part, opts) {
+				return true
+			}
+		}
+		return false
+	}
+	switch t.ext {
+	case '@', '?':
+		if t.ext == '?' && matchGlobTokens(rest, s, opts) {
+			return true
+		}
+		for i := 0; i <= len(s); i++ {
+			if anyAlt(s[:i]) && matchGlobTokens(rest, s[i:], opts) {
+				return true
+			}
+		}
+	case '*', '+':
+		if t.ext == '*' && matchGlobTokens(rest, s, opts) {
+			return true
+		}
+		star := t
+		star.ext = '*'
+		next := append([]globToken{star}, rest...)
+		for i := 1; i <= len(s); i++ {
+			if anyAlt(s[:i]) && matchGlobTokens(next, s[i:], opts) {
+				return true
+			}
+		}
+	case '!':
+		// 与 minimatch 一致：剩余部分不能以任一分支加后续模式的形式匹配
+		for _, alt := range t.alts {
+			seq := append(append([]globToken{}, alt...), rest...)
+			if matchGlobTokens(seq, s, opts) {
+				return false
+			}
+		}
+		for i := 0; i <= len(s); i++ {
+			if matchGlobTokens(rest, s[i:], opts) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func runeEqual(a, b rune, nocase bool) bool {
+	if a == b {
+		return true
+	}
+	return nocase && unicode.ToLower(a) == unicode.ToLower(b)
+}
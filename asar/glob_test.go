@@ -0,0 +1,115 @@
+package asar
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	dot := GlobOptions{Dot: true}
+	base := GlobOptions{MatchBase: true}
+	cases := []struct {
+		pattern, name string
+		opts          GlobOptions
+		want          bool
+	}{
+		// globstar
+		{"**/*.js", "a/b/c.js", GlobOptions{}, true},
+		{"**/*.js", "c.js", GlobOptions{}, true},
+		{"a/**/b", "a/b", GlobOptions{}, true},
+		{"a/**/b", "a/x/y/b", GlobOptions{}, true},
+		{"a/*/c", "a/b/c", GlobOptions{}, true},
+		{"a/*/c", "a/b/x/c", GlobOptions{}, false},
+		{"**/node_modules/**", "node_modules/a/b", GlobOptions{}, true},
+		{"/dist/**", "dist/a.js", GlobOptions{}, true},
+		// dot 规则
+		{"*", ".hidden", GlobOptions{}, false},
+		{"*", ".hidden", dot, true},
+		{"**", ".git/config", GlobOptions{}, false},
+		{"**", ".git/config", dot, true},
+		{"a/.*", "a/.b", GlobOptions{}, true},
+		{"*", "..", dot, false},
+		// extglob
+		{"+(a|b).js", "aab.js", GlobOptions{}, true},
+		{"+(a|b).js", "c.js", GlobOptions{}, false},
+		{"!(*.js)", "a.txt", GlobOptions{}, true},
+		{"!(*.js)", "a.js", GlobOptions{}, false},
+		{"?(x)y", "y", GlobOptions{}, true},
+		{"?(x)y", "xxy", GlobOptions{}, false},
+		{"@(foo|bar)", "bar", GlobOptions{}, true},
+		{"*(ab)", "abab", GlobOptions{}, true},
+		{"+(a|b).js", "a.js", GlobOptions{NoExt: true}, false},
+		// 字符集
+		{"[a-c]x", "bx", GlobOptions{}, true},
+		{"[a-c]x", "dx", GlobOptions{}, false},
+		{"[!a-c]x", "dx", GlobOptions{}, true},
+		{"[[:digit:]]*", "1abc", GlobOptions{}, true},
+		{"[[:alpha:]]*", "1abc", GlobOptions{}, false},
+		{"[abc", "[abc", GlobOptions{}, true},
+		// 花括号
+		{"*.{js,json}", "a.json", GlobOptions{}, true},
+		{"{1..3}.txt", "2.txt", GlobOptions{}, true},
+		{"{1..3}.txt", "4.txt", GlobOptions{}, false},
+		{"{01..03}", "02", GlobOptions{}, true},
+		{"{a..e..2}", "c", GlobOptions{}, true},
+		{"{a..e..2}", "b", GlobOptions{}, false},
+		{"{a,b}", "{a,b}", GlobOptions{NoBrace: true}, true},
+		// matchBase
+		{"*.node", "a/b/x.node", base, true},
+		{"*.node", "a/b/x.node", GlobOptions{}, false},
+		{"b/*.node", "a/b/x.node", base, false},
+		// 取反、注释与大小写
+		{"!*.js", "a.txt", GlobOptions{}, true},
+		{"!*.js", "a.js", GlobOptions{}, false},
+		{"#*", "#a", GlobOptions{}, false},
+		{"*.JS", "a.js", GlobOptions{NoCase: true}, true},
+		{"*.JS", "a.js", GlobOptions{}, false},
+	}
+	for _, c := range cases {
+		if got := MatchGlob(c.pattern, c.name, c.opts); got != c.want {
+			t.Errorf("MatchGlob(%q, %q, %+v) = %v, want %v", c.pattern, c.name, c.opts, got, c.want)
+		}
+	}
+}
+
+func TestGlobExpansionLimit(t *testing.T) {
+	for _, p := range []string{"{1..1000000000}", "{a..\U0010FFFF}", "{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}"} {
+		if _, err := ParseGlob(p, GlobOptions{}); !errors.Is(err, ErrPatternTooLarge) {
+			t.Errorf("ParseGlob(%q) err = %v, want ErrPatternTooLarge", p, err)
+		}
+		if MatchGlob(p, "1", GlobOptions{}) {
+			t.Errorf("MatchGlob(%q) matched", p)
+		}
+	}
+	if _, err := ParseGlob("{1..100}{a..z}", GlobOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// 范围端点靠近整数边界时不能溢出
+	for p, want := range map[string][]string{
+		"{9223372036854775806..9223372036854775807}":   {"9223372036854775806", "9223372036854775807"},
+		"{-9223372036854775808..-9223372036854775807}": {"-9223372036854775808", "-9223372036854775807"},
+		"{9223372036854775807..9223372036854775806}":   {"9223372036854775807", "9223372036854775806"},
+		"{-9223372036854775807..-9223372036854775808}": {"-9223372036854775807", "-9223372036854775808"},
+	} {
+		g, err := ParseGlob(p, GlobOptions{})
+		if err != nil {
+			t.Fatalf("ParseGlob(%q): %v", p, err)
+		}
+		for _, name := range want {
+			if !g.Match(name) {
+				t.Errorf("%q does not match %q", p, name)
+			}
+		}
+		if g.Match("0") {
+			t.Errorf("%q matches 0", p)
+		}
+	}
+	src := testTree(t)
+	for _, options := range []CreateOptions{{Unpack: "{1..1000000000}"}, {Exclude: []string{"{1..1000000000}"}}} {
+		err := CreatePackageWithOptions(src, filepath.Join(t.TempDir(), "app.asar"), options)
+		if !errors.Is(err, ErrPatternTooLarge) {
+			t.Errorf("%+v: err = %v, want ErrPatternTooLarge", options, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package asar
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// fuzzArchive 构建包含文件、目录与链接的小归档，作为模糊测试的种子
+func fuzzArchive(tb testing.TB) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	f, err := w.CreateFile("dir/a.txt", 5, 0o644)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	f.Write([]byte("hello"))
+	if err := w.Symlink("link", "dir/a.txt"); err != nil {
+		tb.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func FuzzReadArchiveHeader(f *testing.F) {
+	archive := fuzzArchive(f)
+	f.Add(archive)
+	f.Add(archive[:8])
+	f.Add(archive[:len(archive)/2])
+	f.Add([]byte{})
+	f.Add([]byte{4, 0, 0, 0, 255, 255, 255, 255})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		header, err := readArchiveHeader(bytes.NewReader(data), int64(len(data)), ReadOptions{})
+		if err != nil {
+			return
+		}
+		root, ok := header.Header.(*FilesystemDirectoryEntry)
+		if !ok {
+			t.Fatalf("header root is %T, want directory", header.Header)
+		}
+		// 丢弃不合法条目后的头必须能通过校验
+		if invalid := validateHeader(root, int64(len(data))-8-int64(header.HeaderSize)); len(invalid) > 0 {
+			t.Fatalf("sanitized header still has invalid entries: %v", invalid[0])
+		}
+		// 限制很小时只能成功或返回 *LimitError
+		small := ReadOptions{MaxEntries: 2, MaxDepth: 1, MaxTotalSize: 3}
+		if _, err := readArchiveHeader(bytes.NewReader(data), int64(len(data)), small); err != nil && !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("unexpected error with small limits: %v", err)
+		}
+		strict, err := readArchiveHeader(bytes.NewReader(data), int64(len(data)), ReadOptions{Strict: true})
+		if (err == nil) != (len(header.Invalid) == 0) || err == nil && len(strict.Invalid) > 0 {
+			t.Fatalf("strict read disagrees with %d invalid entries: %v", len(header.Invalid), err)
+		}
+		r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return
+		}
+		for _, file := range r.File {
+			if rc, err := file.Open(); err == nil {
+				rc.Close()
+			}
+		}
+	})
+}
+
+func FuzzPickle(f *testing.F) {
+	p := NewEmptyPickle()
+	p.WriteString("hello")
+	p.WriteUInt32(42)
+	f.Add(p.ToBuffer())
+	f.Add([]byte{0, 0, 0, 0})
+	f.Add([]byte{8, 0, 0, 0, 255, 255, 255, 255, 0, 0, 0, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := NewPickleFromBuffer(data)
+		if err != nil {
+			return
+		}
+		it := p.NewIterator()
+		for i := 0; i < 4; i++ {
+			if _, err := it.ReadString(); err != nil {
+				break
+			}
+			if _, err := it.ReadUInt32(); err != nil {
+				break
+			}
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package asar
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// IgnoreFileName 打包时自动读取的忽略规则文件名（gitignore 语法）
+const IgnoreFileName = ".asarignore"
+
+// pathFilter 根据 Pattern、Include、Exclude 以及 .asarignore 过滤爬取到的条目
+type pathFilter struct {
+	include    []*Glob
+	exclude    []*Glob
+	readIgnore bool
+	ignores    map[string][]ignoreRule // 目录（"." 为根）-> 该目录下 .asarignore 的规则
+}
+
+// newPathFilter 由打包选项构建过滤器，未设置任何规则时返回 nil 表示不过滤；
+// 隐藏文件由 Dot 单独控制，这里的模式总是可以匹配隐藏段
+func newPathFilter(options CreateOptions) (*pathFilter, error) {
+	f := &pathFilter{readIgnore: !options.NoIgnoreFile, ignores: map[string][]ignoreRule{}}
+	opts := GlobOptions{Dot: true}
+	include := options.Include
+	if options.Pattern != "" {
+		include = append([]string{options.Pattern}, include...)
+	}
+	for _, p := range include {
+		g, err := ParseGlob(p, opts)
+		if err != nil {
+			return nil, err
+		}
+		f.include = append(f.include, g)
+	}
+	excludeOpts := GlobOptions{Dot: true, MatchBase: true}
+	for _, p := range options.Exclude {
+		g, err := ParseGlob(p, excludeOpts)
+		if err != nil {
+			return nil, err
+		}
+		f.exclude = append(f.exclude, g)
+	}
+	if len(f.include) == 0 && len(f.exclude) == 0 && !f.readIgnore {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// loadIgnoreFile 读取目录 dir 下的 .asarignore（不存在时忽略）
+func (f *pathFilter) loadIgnoreFile(fsys fs.FS, dir string) error {
+	if f == nil || !f.readIgnore {
+		return nil
+	}
+	bs, err := fs.ReadFile(fsys, path.Join(dir, IgnoreFileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if rules := parseIgnoreRules(string(bs), dir); len(rules) > 0 {
+		f.ignores[dir] = rules
+	}
+	return nil
+}
+
+// excluded 判断条目是否被排除；被排除的目录不再向下遍历
+func (f *pathFilter) excluded(name string, isDir bool) bool {
+	if f == nil {
+		return false
+	}
+	if f.readIgnore && path.Base(name) == IgnoreFileName {
+		return true
+	}
+	for _, g := range f.exclude {
+		if g.Match(name) {
+			return true
+		}
+	}
+	return f.ignored(name, isDir)
+}
+
+// ignored 依次应用从根目录到父目录的 .asarignore 规则，最后一条匹配的规则生效
+func (f *pathFilter) ignored(name string, isDir bool) bool {
+	if len(f.ignores) == 0 {
+		return false
+	}
+	dirs := []string{"."}
+	parent := path.Dir(name)
+	if parent != "." {
+		parts := strings.Split(parent, "/")
+		for i := range parts {
+			dirs = append(dirs, strings.Join(parts[:i+1], "/"))
+		}
+	}
+	ignored := false
+	for _, dir := range dirs {
+		for _, r := range f.ignores[dir] {
+			if r.match(name, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// included 判断条目是否匹配任一包含模式；未设置包含模式时全部包含
+func (f *pathFilter) included(name string) bool {
+	if f == nil || len(f.include) == 0 {
+		return true
+	}
+	for _, g := range f.include {
+		if g.Match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreRule 单条 gitignore 规则
+type ignoreRule struct {
+	glob    *Glob
+	base    string // 规则文件所在目录，"." 为根
+	negate  bool
+	dirOnly bool
+}
+
+// parseIgnoreRules 按 gitignore 语法解析规则：支持 '#' 注释、'!' 取反、结尾 '/' 仅匹配目录，
+// 含 '/' 的模式相对于规则文件所在目录锚定，否则匹配任意层级的名称
+func parseIgnoreRules(content, base string) []ignoreRule {
+	rules := make([]ignoreRule, 0)
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		line = trimIgnoreTrailingSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if !anchored && !strings.HasPrefix(line, "**") {
+			line = "**/" + line
+		}
+		r.glob = CompileGlob(line, GlobOptions{Dot: true, NoBrace: true, NoExt: true, NoNegate: true})
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// trimIgnoreTrailingSpace 去掉未转义的行尾空格
+func trimIgnoreTrailingSpace(line string) string {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-2] + " "
+	}
+	return line
+}
+
+func (r ignoreRule) match(name string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel := name
+	if r.base != "." {
+		if !strings.HasPrefix(name, r.base+"/") {
+			return false
+		}
+		rel = strings.TrimPrefix(name, r.base+"/")
+	}
+	return r.glob.Match(rel)
+}
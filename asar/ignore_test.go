@@ -0,0 +1,50 @@
+package asar
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIncludePatterns(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{
+		"package.json":          "{}",
+		"dist/main.js":          "main",
+		"dist/lib/util.js":      "util",
+		"src/x.ts":              "x",
+		"node_modules/a/a.json": "{}",
+	})
+	cases := []struct {
+		options CreateOptions
+		want    []string
+	}{
+		{CreateOptions{Include: []string{"dist/**", "package.json"}}, []string{"dist", "dist/lib", "dist/lib/util.js", "dist/main.js", "package.json"}},
+		{CreateOptions{Pattern: "dist/**"}, []string{"dist", "dist/lib", "dist/lib/util.js", "dist/main.js"}},
+		{CreateOptions{Pattern: "dist/**", Include: []string{"package.json"}}, []string{"dist", "dist/lib", "dist/lib/util.js", "dist/main.js", "package.json"}},
+		{CreateOptions{Exclude: []string{"src", "*.json"}}, []string{"dist", "dist/lib", "dist/lib/util.js", "dist/main.js", "node_modules", "node_modules/a"}},
+	}
+	for _, c := range cases {
+		got := archiveFiles(t, packTree(t, src, c.options))
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%+v: got %v, want %v", c.options, got, c.want)
+		}
+	}
+}
+
+func TestAsarIgnore(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{
+		".asarignore":     "*.log\nbuild/\n!keep.log\n",
+		"a.log":           "a",
+		"keep.log":        "k",
+		"build/out.js":    "o",
+		"sub/.asarignore": "*.tmp\n",
+		"sub/x.tmp":       "x",
+		"sub/y.txt":       "y",
+		"x.tmp":           "x",
+	})
+	want := []string{"keep.log", "sub", "sub/y.txt", "x.tmp"}
+	if got := archiveFiles(t, packTree(t, src, CreateOptions{})); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
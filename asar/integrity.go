@@ -3,7 +3,9 @@ package asar
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"hash"
 	"io"
+	"sync"
 )
 
 const (
@@ -13,6 +15,17 @@ const (
 	BLOCK_SIZE = 4 * 1024 * 1024
 )
 
+// copyBufferSize 流式复制与哈希时使用的缓冲区大小
+const copyBufferSize = 256 * 1024
+
+// copyBufferPool 复用复制缓冲区，避免为每个文件分配
+var copyBufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, copyBufferSize)
+		return &buf
+	},
+}
+
 // FileIntegrity 表示文件完整性信息
 type FileIntegrity struct {
 	Algorithm string   `json:"algorithm"`
@@ -21,36 +34,76 @@ type FileIntegrity struct {
 	Blocks    []string `json:"blocks"`
 }
 
-// GetFileIntegrity 计算输入流的完整性信息，包含整文件哈希与分块哈希
-func GetFileIntegrity(r io.Reader) (FileIntegrity, error) {
-	h := sha256.New()
-	blocks := make([]string, 0)
-	buf := make([]byte, BLOCK_SIZE)
-	for {
-		n, err := io.ReadFull(r, buf)
-		if err == io.ErrUnexpectedEOF {
-			if n > 0 {
-				hb := sha256.Sum256(buf[:n])
-				blocks = append(blocks, hex.EncodeToString(hb[:]))
-				h.Write(buf[:n])
-			}
-			break
-		}
-		if err == io.EOF {
-			break
+// IntegrityWriter 以流的方式计算完整性信息：写入的数据同时累计整文件哈希与 4MB 分块哈希，
+// 无需缓存整块数据，可与 io.MultiWriter 组合在复制时顺带计算
+type IntegrityWriter struct {
+	hash   hash.Hash
+	block  hash.Hash
+	n      int // 当前分块已写入的字节数
+	blocks []string
+}
+
+// NewIntegrityWriter 创建 IntegrityWriter
+func NewIntegrityWriter() *IntegrityWriter {
+	return &IntegrityWriter{hash: sha256.New(), block: sha256.New(), blocks: make([]string, 0)}
+}
+
+// Write 写入数据，总是返回 len(p), nil
+func (w *IntegrityWriter) Write(p []byte) (int, error) {
+	total := len(p)
+	w.hash.Write(p)
+	for len(p) > 0 {
+		chunk := BLOCK_SIZE - w.n
+		if chunk > len(p) {
+			chunk = len(p)
 		}
-		if err != nil {
-			return FileIntegrity{}, err
+		w.block.Write(p[:chunk])
+		w.n += chunk
+		p = p[chunk:]
+		if w.n == BLOCK_SIZE {
+			w.blocks = append(w.blocks, hex.EncodeToString(w.block.Sum(nil)))
+			w.block.Reset()
+			w.n = 0
 		}
-		hb := sha256.Sum256(buf[:n])
-		blocks = append(blocks, hex.EncodeToString(hb[:]))
-		h.Write(buf[:n])
 	}
-	sum := h.Sum(nil)
+	return total, nil
+}
+
+// Integrity 返回截至目前写入数据的完整性信息，不影响后续写入
+func (w *IntegrityWriter) Integrity() FileIntegrity {
+	blocks := make([]string, len(w.blocks), len(w.blocks)+1)
+	copy(blocks, w.blocks)
+	if w.n > 0 {
+		blocks = append(blocks, hex.EncodeToString(w.block.Sum(nil)))
+	}
 	return FileIntegrity{
 		Algorithm: ALGORITHM,
-		Hash:      hex.EncodeToString(sum),
+		Hash:      hex.EncodeToString(w.hash.Sum(nil)),
 		BlockSize: BLOCK_SIZE,
 		Blocks:    blocks,
-	}, nil
+	}
+}
+
+// Reset 清空状态以便复用
+func (w *IntegrityWriter) Reset() {
+	w.hash.Reset()
+	w.block.Reset()
+	w.n = 0
+	w.blocks = w.blocks[:0]
+}
+
+// GetFileIntegrity 计算输入流的完整性信息，包含整文件哈希与分块哈希
+func GetFileIntegrity(r io.Reader) (FileIntegrity, error) {
+	w := NewIntegrityWriter()
+	if _, err := copyBuffer(w, r); err != nil {
+		return FileIntegrity{}, err
+	}
+	return w.Integrity(), nil
+}
+
+// copyBuffer 使用池化缓冲区执行 io.CopyBuffer
+func copyBuffer(dst io.Writer, src io.Reader) (int64, error) {
+	buf := copyBufferPool.Get().(*[]byte)
+	defer copyBufferPool.Put(buf)
+	return io.CopyBuffer(dst, src, *buf)
 }
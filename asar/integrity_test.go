@@ -0,0 +1,68 @@
+package asar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// wantIntegrity 独立计算 data 的整文件哈希与 4MB 分块哈希
+func wantIntegrity(data []byte) FileIntegrity {
+	sum := sha256.Sum256(data)
+	fi := FileIntegrity{Algorithm: ALGORITHM, Hash: hex.EncodeToString(sum[:]), BlockSize: BLOCK_SIZE, Blocks: []string{}}
+	for off := 0; off < len(data); off += BLOCK_SIZE {
+		block := sha256.Sum256(data[off:min(off+BLOCK_SIZE, len(data))])
+		fi.Blocks = append(fi.Blocks, hex.EncodeToString(block[:]))
+	}
+	return fi
+}
+
+func TestIntegrityWriterBlocks(t *testing.T) {
+	data := make([]byte, 2*BLOCK_SIZE+3)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	// 以不整齐的分片写入，跨越分块边界
+	for _, chunks := range [][]int{
+		{},
+		{1},
+		{BLOCK_SIZE},
+		{1, BLOCK_SIZE - 1},
+		{1, BLOCK_SIZE - 1, BLOCK_SIZE + 3},
+		{3, BLOCK_SIZE, BLOCK_SIZE - 1},
+		{2*BLOCK_SIZE + 2},
+	} {
+		w := NewIntegrityWriter()
+		n := 0
+		for _, c := range chunks {
+			w.Write(data[n : n+c])
+			n += c
+		}
+		if got, want := w.Integrity(), wantIntegrity(data[:n]); !reflect.DeepEqual(got, want) {
+			t.Errorf("chunks %v: integrity %+v, want %+v", chunks, got, want)
+		}
+		if got, _ := GetFileIntegrity(bytes.NewReader(data[:n])); !reflect.DeepEqual(got, wantIntegrity(data[:n])) {
+			t.Errorf("GetFileIntegrity of %d bytes differs", n)
+		}
+	}
+}
+
+// TestIntegrityEmptyFile 空文件的 blocks 为 []，与 placeholderIntegrity(0) 序列化后长度一致
+func TestIntegrityEmptyFile(t *testing.T) {
+	got := NewIntegrityWriter().Integrity()
+	bs, _ := json.Marshal(got)
+	placeholder, _ := json.Marshal(placeholderIntegrity(0))
+	if got.Blocks == nil || len(got.Blocks) != 0 || len(bs) != len(placeholder) {
+		t.Fatalf("empty integrity %s, placeholder %s", bs, placeholder)
+	}
+	for _, size := range []int64{1, BLOCK_SIZE, BLOCK_SIZE + 1} {
+		real, _ := json.Marshal(wantIntegrity(make([]byte, size)))
+		placeholder, _ := json.Marshal(placeholderIntegrity(size))
+		if len(real) != len(placeholder) {
+			t.Errorf("size %d: placeholder is %d bytes, integrity %d", size, len(placeholder), len(real))
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package asar
+
+import (
+	"errors"
+	"strconv"
+)
+
+// 读取归档头时的默认限制，足以容纳大型 Electron 应用
+const (
+	DefaultMaxHeaderSize = 256 << 20
+	DefaultMaxEntries    = 4 << 20
+	DefaultMaxDepth      = 512
+	DefaultMaxTotalSize  = int64(1) << 40
+)
+
+// ErrLimitExceeded 头超出 ReadOptions 中的限制；可用 errors.Is 判断，*LimitError 携带详细信息
+var ErrLimitExceeded = errors.New("asar: archive exceeds read limits")
+
+// LimitError 描述被超出的限制，用于与头损坏区分
+type LimitError struct {
+	// Limit 为限制名称：header size、entries、depth 或 total size
+	Limit string
+	// Value 为触发限制时的取值，Max 为允许的最大值
+	Value int64
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return "asar: " + e.Limit + " " + strconv.FormatInt(e.Value, 10) + " exceeds limit " + strconv.FormatInt(e.Max, 10)
+}
+
+// Is 使 errors.Is(err, ErrLimitExceeded) 成立
+func (e *LimitError) Is(target error) bool { return target == ErrLimitExceeded }
+
+// headerLimits 为解析头时生效的限制与计数
+type headerLimits struct {
+	maxEntries   int64
+	maxDepth     int64
+	maxTotalSize int64
+	entries      int64
+	totalSize    int64
+}
+
+func newHeaderLimits(options ReadOptions) *headerLimits {
+	return &headerLimits{
+		maxEntries:   limitOrDefault(int64(options.MaxEntries), DefaultMaxEntries),
+		maxDepth:     limitOrDefault(int64(options.MaxDepth), DefaultMaxDepth),
+		maxTotalSize: limitOrDefault(options.MaxTotalSize, DefaultMaxTotalSize),
+	}
+}
+
+// maxHeaderSize 返回生效的头大小上限，小于 0 表示不限制
+func (o ReadOptions) maxHeaderSize() int64 {
+	return limitOrDefault(int64(o.MaxHeaderSize), DefaultMaxHeaderSize)
+}
+
+// limitOrDefault 0 取默认值，负数表示不限制（返回 -1）
+func limitOrDefault(v, def int64) int64 {
+	switch {
+	case v == 0:
+		return def
+	case v < 0:
+		return -1
+	}
+	return v
+}
+
+// enter 记录一个位于 depth 层的条目
+func (l *headerLimits) enter(depth int) error {
+	l.entries++
+	if l.maxEntries >= 0 && l.entries > l.maxEntries {
+		return &LimitError{Limit: "entries", Value: l.entries, Max: l.maxEntries}
+	}
+	if l.maxDepth >= 0 && int64(depth) > l.maxDepth {
+		return &LimitError{Limit: "depth", Value: int64(depth), Max: l.maxDepth}
+	}
+	return nil
+}
+
+// addSize 累计文件声明的大小
+func (l *headerLimits) addSize(size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	if l.maxTotalSize >= 0 && size > l.maxTotalSize-l.totalSize {
+		value := l.totalSize + size
+		if value < 0 {
+			value = int64(^uint64(0) >> 1)
+		}
+		return &LimitError{Limit: "total size", Value: value, Max: l.maxTotalSize}
+	}
+	l.totalSize += size
+	return nil
+}
@@ -0,0 +1,72 @@
+package asar
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// rawArchive 以 header 作为头 JSON 构建不含数据的归档
+func rawArchive(header string) []byte {
+	hp := NewEmptyPickle()
+	hp.WriteString(header)
+	hb := hp.ToBuffer()
+	sp := NewEmptyPickle()
+	sp.WriteUInt32(uint32(len(hb)))
+	return append(sp.ToBuffer(), hb...)
+}
+
+func readRawHeader(header string, options ReadOptions) error {
+	data := rawArchive(header)
+	_, err := readArchiveHeader(bytes.NewReader(data), int64(len(data)), options)
+	return err
+}
+
+func TestHeaderLimits(t *testing.T) {
+	files := `{"files":{"a":{"size":0},"b":{"size":0},"c":{"size":0},"d":{"size":4}}}`
+	nested := `{"files":{"a":{"files":{"b":{"files":{"c":{"files":{}}}}}}}}`
+	// 超出数量限制的条目之后是损坏的 JSON：限制应在读完整个头之前生效
+	truncated := `{"files":{"a":{"size":0},"b":{"size":0},"c":{"size":0},` + strings.Repeat(`"x`, 1000)
+	tests := []struct {
+		header  string
+		options ReadOptions
+		limit   string
+	}{
+		{files, ReadOptions{MaxEntries: 3}, "entries"},
+		{truncated, ReadOptions{MaxEntries: 2}, "entries"},
+		{nested, ReadOptions{MaxDepth: 2}, "depth"},
+		{files, ReadOptions{MaxTotalSize: 3}, "total size"},
+		// 不限制 MaxDepth 时仍受 maxNestedEntries 约束
+		{strings.Repeat(`{"files":{"d":`, maxNestedEntries+1) + `{"files":{}}` + strings.Repeat(`}}`, maxNestedEntries+1), ReadOptions{MaxDepth: -1}, "depth"},
+	}
+	for _, tt := range tests {
+		err := readRawHeader(tt.header, tt.options)
+		var le *LimitError
+		if !errors.Is(err, ErrLimitExceeded) || !errors.As(err, &le) || le.Limit != tt.limit {
+			t.Errorf("%+v: err = %v, want %s limit", tt.options, err, tt.limit)
+		}
+	}
+	for _, header := range []string{files, nested} {
+		if err := readRawHeader(header, ReadOptions{MaxEntries: 4, MaxDepth: 4, MaxTotalSize: 4}); err != nil {
+			t.Errorf("header within limits: %v", err)
+		}
+	}
+}
+
+func TestCorruptHeader(t *testing.T) {
+	for _, header := range []string{
+		``,
+		`not json`,
+		`[]`,
+		`{"files":1}`,
+		`{"size":1}`,
+		`{"files":{}} {}`,
+		`{"files":{"a":{"size":1}`,
+		`{"files":{"a":{"files":{"b":{"size":]}}}}`,
+	} {
+		if err := readRawHeader(header, ReadOptions{}); !errors.Is(err, ErrCorruptHeader) {
+			t.Errorf("%q: err = %v, want ErrCorruptHeader", header, err)
+		}
+	}
+}
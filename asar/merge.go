@@ -0,0 +1,228 @@
+package asar
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// MergeConflict 多个来源包含同一路径（且不都是目录）时的处理方式
+type MergeConflict int
+
+const (
+	// MergeConflictError 遇到冲突时返回错误
+	MergeConflictError MergeConflict = iota
+	// MergeFirstWins 保留先出现的来源中的条目
+	MergeFirstWins
+	// MergeLastWins 使用后出现的来源中的条目替换已有条目（目录连同其内容）
+	MergeLastWins
+)
+
+// MergeSource 参与合并的归档及其挂载路径
+type MergeSource struct {
+	Archive string
+	// Prefix 归档内容在合并结果中的挂载目录（如 "plugins/foo"），为空时挂载到根目录
+	Prefix string
+}
+
+// MergeOptions 合并选项
+type MergeOptions struct {
+	Conflict MergeConflict
+}
+
+// mergeFile 记录合并结果中文件的来源
+type mergeFile struct {
+	src    int    // sources 下标
+	name   string // 在来源归档内的路径
+	offset int64
+}
+
+// Merge 按顺序将多个归档合并为 dest：同名目录合并，其余冲突按 options.Conflict 处理；
+// 数据直接从来源归档复制，不解包重打，链接目标按挂载路径调整，.unpacked 目录一并合并。
+// 写入过程与打包一样先写临时文件再重命名
+func Merge(dest string, sources []MergeSource, options MergeOptions) error {
+	dest, err := filepath.Abs(dest)
+	if err != nil {
+		return err
+	}
+	root := &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{}}
+	files := map[*FilesystemFileEntry]mergeFile{}
+	archives := make([]string, len(sources))
+	dataStarts := make([]int64, len(sources))
+	for i, source := range sources {
+		archives[i], err = filepath.Abs(source.Archive)
+		if err != nil {
+			return err
+		}
+		fsys, err := loadFilesystem(archives[i], ReadOptions{Strict: true})
+		if err != nil {
+			return err
+		}
+		prefix := cleanEntryName(source.Prefix)
+		if prefix == "." {
+			prefix = ""
+		}
+		walkEntries(fsys.header, func(p string, entry FilesystemEntry) error {
+			switch t := entry.(type) {
+			case *FilesystemFileEntry:
+				off, _ := strconv.ParseInt(t.Offset, 10, 64)
+				files[t] = mergeFile{src: i, name: p, offset: off}
+			case *FilesystemLinkEntry:
+				t.Link = path.Join(prefix, t.Link)
+			}
+			return nil
+		})
+		dataStarts[i] = int64(8 + fsys.GetHeaderSize())
+		// 挂载到子目录时，相当于把以 prefix 末级名称包装的根目录合并到其上级目录
+		dst, src, at := root, fsys.header.(*FilesystemDirectoryEntry), ""
+		if prefix != "" {
+			parent, base, err := entryParent(root, prefix)
+			if err != nil {
+				return &PathError{Op: "merge", Archive: archives[i], Path: prefix, Err: err}
+			}
+			dst, src = parent, &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{base: src}}
+			if at = path.Dir(prefix); at == "." {
+				at = ""
+			}
+		}
+		if err := mergeEntries(dst, src, at, archives[i], options.Conflict); err != nil {
+			return err
+		}
+	}
+
+	// 按路径顺序为打包文件分配偏移，来源中共用同一段数据的条目仍然共用
+	type span struct {
+		src          int
+		offset, size int64
+	}
+	relocated := map[span]int64{}
+	var ranges []span
+	var offset int64
+	type unpackedEntry struct {
+		name  string
+		entry FilesystemEntry
+	}
+	var unpacked []unpackedEntry
+	walkEntries(root, func(p string, entry FilesystemEntry) error {
+		switch t := entry.(type) {
+		case *FilesystemFileEntry:
+			if t.Unpacked {
+				unpacked = append(unpacked, unpackedEntry{p, t})
+				return nil
+			}
+			mf := files[t]
+			key := span{mf.src, mf.offset, int64(t.Size)}
+			if off, ok := relocated[key]; ok {
+				t.Offset = strconv.FormatInt(off, 10)
+				return nil
+			}
+			relocated[key] = offset
+			t.Offset = strconv.FormatInt(offset, 10)
+			ranges = append(ranges, key)
+			offset += int64(t.Size)
+		case *FilesystemLinkEntry:
+			if t.Unpacked {
+				unpacked = append(unpacked, unpackedEntry{p, t})
+			}
+		}
+		return nil
+	})
+
+	inputs := make([]*os.File, len(sources))
+	defer func() {
+		for _, f := range inputs {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+	output, err := newAtomicOutput(dest, len(unpacked) > 0)
+	if err != nil {
+		return err
+	}
+	defer output.cleanup()
+	if err := writeFilesystemHeader(output.file, root); err != nil {
+		return err
+	}
+	for _, r := range ranges {
+		if inputs[r.src] == nil {
+			if inputs[r.src], err = os.Open(archives[r.src]); err != nil {
+				return err
+			}
+		}
+		n, err := copyBuffer(output.file, io.NewSectionReader(inputs[r.src], dataStarts[r.src]+r.offset, r.size))
+		if err != nil {
+			return err
+		}
+		if n != r.size {
+			return corruptHeader("file data is out of the archive bounds", io.ErrUnexpectedEOF)
+		}
+	}
+	for _, u := range unpacked {
+		if err := mergeUnpacked(archives, files, output.unpacked, u.name, u.entry); err != nil {
+			return &PathError{Op: "merge", Archive: dest, Path: u.name, Err: err}
+		}
+	}
+	return output.commit()
+}
+
+// mergeUnpacked 在新的 .unpacked 目录中写出条目，文件内容取自其来源归档的 .unpacked 目录
+func mergeUnpacked(archives []string, files map[*FilesystemFileEntry]mergeFile, base, name string, entry FilesystemEntry) error {
+	f, ok := entry.(*FilesystemFileEntry)
+	if !ok {
+		return copyUnpackedEntry("", base, name, entry, nil, 0)
+	}
+	mf := files[f]
+	p, err := unpackedPath(archives[mf.src], mf.name)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return copyUnpackedEntry(archives[mf.src], base, name, entry, in, 0)
+}
+
+// mergeEntries 将 src 的子项合并到 dst：两侧都是目录时递归合并，否则按 conflict 处理
+func mergeEntries(dst, src *FilesystemDirectoryEntry, prefix, archive string, conflict MergeConflict) error {
+	names := make([]string, 0, len(src.Files))
+	for name := range src.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := name
+		if prefix != "" {
+			p = prefix + "/" + name
+		}
+		entry := src.Files[name]
+		existing, ok := dst.Files[name]
+		if !ok {
+			dst.Files[name] = entry
+			continue
+		}
+		ed, eIsDir := existing.(*FilesystemDirectoryEntry)
+		sd, sIsDir := entry.(*FilesystemDirectoryEntry)
+		if eIsDir && sIsDir {
+			ed.Unpacked = ed.Unpacked || sd.Unpacked
+			if err := mergeEntries(ed, sd, p, archive, conflict); err != nil {
+				return err
+			}
+			continue
+		}
+		switch conflict {
+		case MergeFirstWins:
+		case MergeLastWins:
+			dst.Files[name] = entry
+		default:
+			return &PathError{Op: "merge", Archive: archive, Path: p, Err: fs.ErrExist}
+		}
+	}
+	return nil
+}
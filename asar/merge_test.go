@@ -0,0 +1,45 @@
+package asar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeRoundTrip(t *testing.T) {
+	core := testTree(t)
+	plugin := t.TempDir()
+	writeTree(t, plugin, map[string]string{"index.js": "module.exports = 1\n", "a.txt": "plugin", "bin/run.sh": "#!/bin/sh\n"})
+	if err := os.Symlink("index.js", filepath.Join(plugin, "main.js")); err != nil {
+		t.Fatal(err)
+	}
+	coreArchive := packTree(t, core, CreateOptions{UnpackDir: "assets", Compress: "*.js"})
+	pluginArchive := packTree(t, plugin, CreateOptions{UnpackDir: "bin"})
+
+	out := filepath.Join(t.TempDir(), "app.asar")
+	sources := []MergeSource{{Archive: coreArchive}, {Archive: pluginArchive, Prefix: "plugins/p"}}
+	if err := Merge(out, sources, MergeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	if err := ExtractAll(out, dest); err != nil {
+		t.Fatal(err)
+	}
+	compareTree(t, core, dest)
+	compareTree(t, plugin, filepath.Join(dest, "plugins", "p"))
+
+	// 挂载到同一目录时 a.txt 冲突
+	sources[1].Prefix = ""
+	if err := Merge(filepath.Join(t.TempDir(), "x.asar"), sources, MergeOptions{}); err == nil {
+		t.Error("conflicting merge succeeded")
+	}
+	for conflict, want := range map[MergeConflict]string{MergeFirstWins: "hello", MergeLastWins: "plugin"} {
+		out := filepath.Join(t.TempDir(), "app.asar")
+		if err := Merge(out, sources, MergeOptions{Conflict: conflict}); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ExtractFile(out, "a.txt", false); err != nil || string(got) != want {
+			t.Errorf("conflict %d: a.txt = %q, %v; want %q", conflict, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package asar
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// whiteoutPrefix 白障条目的名称前缀：上层中名为 ".wh.<name>" 的条目（任意类型）会隐藏下层同一目录中的 <name>
+const whiteoutPrefix = ".wh."
+
+// Overlay 将多个归档与目录按顺序叠加为一个只读视图，靠前的层遮盖靠后的层：
+// 同名目录合并，其余条目取最上层的版本，白障条目隐藏下层的同名条目。链接在叠加后的视图中解析。
+// 构建完成后可并发使用
+type Overlay struct {
+	layers []overlayLayer
+}
+
+// overlayLayer 叠加视图中的一层：归档或磁盘目录
+type overlayLayer struct {
+	fsys    *Filesystem // 归档层
+	modTime time.Time   // 归档文件的修改时间
+	dir     string      // 目录层
+}
+
+// NewOverlay 创建空的叠加视图，通过 AddFilesystem 与 AddDir 从上到下依次添加各层
+func NewOverlay() *Overlay {
+	return &Overlay{}
+}
+
+// OpenOverlay 按顺序叠加 paths 中的目录与归档（归档头经由全局缓存读取），第一个路径位于最上层
+func OpenOverlay(paths ...string) (*Overlay, error) {
+	o := NewOverlay()
+	for _, p := range paths {
+		st, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if st.IsDir() {
+			if err := o.AddDir(p); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		fsys, err := ReadFilesystemSync(p)
+		if err != nil {
+			return nil, err
+		}
+		o.AddFilesystem(fsys)
+	}
+	return o, nil
+}
+
+// AddFilesystem 在最下方添加一个归档层
+func (o *Overlay) AddFilesystem(fsys *Filesystem) {
+	l := overlayLayer{fsys: fsys}
+	if st, err := os.Stat(fsys.GetRootPath()); err == nil {
+		l.modTime = st.ModTime()
+	}
+	o.layers = append(o.layers, l)
+}
+
+// AddDir 在最下方添加一个目录层
+func (o *Overlay) AddDir(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	st, err := os.Stat(abs)
+	if err != nil {
+		return err
+	}
+	if !st.IsDir() {
+		return &fs.PathError{Op: "overlay", Path: dir, Err: errors.New("not a directory")}
+	}
+	o.layers = append(o.layers, overlayLayer{dir: abs})
+	return nil
+}
+
+// GetFile 返回叠加视图中的条目（可解析符号链接）；目录条目只包含其所在层的子项，完整的子项列表见 ReadDir。
+// 目录层中的文件没有 integrity 信息
+func (o *Overlay) GetFile(p string, followLinks bool) (FilesystemEntry, error) {
+	entry, _, _, err := o.find(p, followLinks)
+	if err != nil {
+		return nil, &PathError{Op: "stat", Path: p, Err: err}
+	}
+	return entry, nil
+}
+
+// ReadFile 读取叠加视图中的文件内容，符号链接会被解析
+func (o *Overlay) ReadFile(p string) ([]byte, error) {
+	entry, real, layer, err := o.find(p, true)
+	if err != nil {
+		return nil, &PathError{Op: "read", Path: p, Err: err}
+	}
+	f, ok := entry.(*FilesystemFileEntry)
+	if !ok {
+		return nil, &PathError{Op: "read", Path: p, Err: ErrNotFile}
+	}
+	l := o.layers[layer]
+	if l.fsys != nil {
+		data, err := ReadFileSync(l.fsys, real, f)
+		if err != nil {
+			return nil, pathError("read", l.fsys.src, real, err)
+		}
+		return data, nil
+	}
+	return os.ReadFile(l.path(real))
+}
+
+// ReadDir 返回叠加视图中目录的子项（按名称排序），各层的同名目录合并，白障条目本身不会列出。
+// 归档中条目的 Info().Sys() 为对应的 FilesystemEntry
+func (o *Overlay) ReadDir(p string) ([]fs.DirEntry, error) {
+	entry, real, _, err := o.find(p, true)
+	if err != nil {
+		return nil, &PathError{Op: "readdir", Path: p, Err: err}
+	}
+	if _, ok := entry.(*FilesystemDirectoryEntry); !ok {
+		return nil, &PathError{Op: "readdir", Path: p, Err: errors.New("not a directory")}
+	}
+	seen := map[string]bool{}
+	hidden := map[string]bool{}
+	out := make([]fs.DirEntry, 0)
+	for _, l := range o.layers {
+		e, blocked, err := l.lookup(real)
+		if err != nil {
+			return nil, &PathError{Op: "readdir", Path: p, Err: err}
+		}
+		if e == nil && !blocked {
+			continue
+		}
+		if _, isDir := e.(*FilesystemDirectoryEntry); blocked || !isDir {
+			break
+		}
+		entries, err := l.readDir(real, e.(*FilesystemDirectoryEntry))
+		if err != nil {
+			return nil, &PathError{Op: "readdir", Path: p, Err: err}
+		}
+		var whiteouts []string
+		for _, de := range entries {
+			name := de.Name()
+			if strings.HasPrefix(name, whiteoutPrefix) {
+				whiteouts = append(whiteouts, strings.TrimPrefix(name, whiteoutPrefix))
+				continue
+			}
+			if seen[name] || hidden[name] {
+				continue
+			}
+			seen[name] = true
+			out = append(out, de)
+		}
+		for _, name := range whiteouts {
+			hidden[name] = true
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out, nil
+}
+
+// ListFiles 列出叠加视图中的全部路径（以 '/' 开头，按名称排序、深度优先），不进入符号链接指向的目录
+func (o *Overlay) ListFiles() []string {
+	files := make([]string, 0)
+	var fill func(dir string)
+	fill = func(dir string) {
+		entries, err := o.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		for _, de := range entries {
+			full := path.Join(dir, de.Name())
+			files = append(files, "/"+full)
+			if de.IsDir() {
+				fill(full)
+			}
+		}
+	}
+	fill("")
+	return files
+}
+
+// find 在叠加视图中逐级查找路径，返回条目、解析后的真实路径与所在层；
+// 中间路径段上的符号链接总会被解析，末级链接仅在 followLinks 为 true 时解析
+func (o *Overlay) find(p string, followLinks bool) (FilesystemEntry, string, int, error) {
+	parts := splitPath(path.Clean("/" + filepath.ToSlash(p)))
+	var entry FilesystemEntry = &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{}}
+	real, layer, hops := "", 0, 0
+	for i := 0; i < len(parts); i++ {
+		next := path.Join(real, parts[i])
+		var err error
+		entry, layer, err = o.entryAt(next)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		last := i == len(parts)-1
+		if link, ok := entry.(*FilesystemLinkEntry); ok && (!last || followLinks) {
+			if hops++; hops > maxLinkHops {
+				return nil, "", 0, errTooManyLinks
+			}
+			if linkTargetEscapes(link.Link) {
+				return nil, "", 0, linkEscapes(link.Link)
+			}
+			parts = append(splitPath(path.Clean(link.Link)), parts[i+1:]...)
+			real, i = "", -1
+			entry = &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{}}
+			continue
+		}
+		if _, isDir := entry.(*FilesystemDirectoryEntry); !last && !isDir {
+			return nil, "", 0, ErrNotFound
+		}
+		real = next
+	}
+	return entry, real, layer, nil
+}
+
+// entryAt 返回真实路径 p（不含链接）在最上层可见的条目及其所在层；白障条目本身不可见
+func (o *Overlay) entryAt(p string) (FilesystemEntry, int, error) {
+	if strings.HasPrefix(path.Base(p), whiteoutPrefix) {
+		return nil, 0, ErrNotFound
+	}
+	for i, l := range o.layers {
+		entry, blocked, err := l.lookup(p)
+		if err != nil {
+			return nil, 0, err
+		}
+		if blocked {
+			break
+		}
+		if entry != nil {
+			return entry, i, nil
+		}
+	}
+	return nil, 0, ErrNotFound
+}
+
+// lookup 在本层中查找 p：找到时返回条目；blocked 为 true 表示本层的白障或非目录条目遮盖了 p，下层不再可见
+func (l overlayLayer) lookup(p string) (FilesystemEntry, bool, error) {
+	parts := splitPath(p)
+	if l.fsys != nil {
+		cur, _ := l.fsys.header.(*FilesystemDirectoryEntry)
+		if len(parts) == 0 {
+			return cur, false, nil
+		}
+		for i, part := range parts {
+			if _, ok := cur.Files[whiteoutPrefix+part]; ok {
+				return nil, true, nil
+			}
+			child, ok := cur.Files[part]
+			if !ok {
+				return nil, false, nil
+			}
+			if i == len(parts)-1 {
+				return child, false, nil
+			}
+			d, ok := child.(*FilesystemDirectoryEntry)
+			if !ok {
+				return nil, true, nil
+			}
+			cur = d
+		}
+		return nil, false, nil
+	}
+	cur := l.dir
+	if len(parts) == 0 {
+		return &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{}}, false, nil
+	}
+	for i, part := range parts {
+		if _, err := os.Lstat(filepath.Join(cur, whiteoutPrefix+part)); err == nil {
+			return nil, true, nil
+		}
+		next := filepath.Join(cur, part)
+		fi, err := os.Lstat(next)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if i == len(parts)-1 {
+			entry, err := l.diskEntry(p, fi)
+			return entry, false, err
+		}
+		if !fi.IsDir() {
+			return nil, true, nil
+		}
+		cur = next
+	}
+	return nil, false, nil
+}
+
+// diskEntry 将目录层中的文件信息转换为头条目，链接目标记录为相对于层根目录的路径
+func (l overlayLayer) diskEntry(p string, fi fs.FileInfo) (FilesystemEntry, error) {
+	switch {
+	case fi.IsDir():
+		return &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{}}, nil
+	case fi.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(l.path(p))
+		if err != nil {
+			return nil, err
+		}
+		return &FilesystemLinkEntry{Link: dirFS(l.dir).archiveLink(p, target)}, nil
+	}
+	return &FilesystemFileEntry{Size: int(fi.Size()), Executable: isExecutable(fi)}, nil
+}
+
+// readDir 列出本层目录 p 的子项（包括白障条目）
+func (l overlayLayer) readDir(p string, dir *FilesystemDirectoryEntry) ([]fs.DirEntry, error) {
+	if l.fsys == nil {
+		return os.ReadDir(l.path(p))
+	}
+	out := make([]fs.DirEntry, 0, len(dir.Files))
+	for name, child := range dir.Files {
+		out = append(out, fs.FileInfoToDirEntry(&fileInfo{name: name, entry: child, modTime: l.modTime}))
+	}
+	return out, nil
+}
+
+func (l overlayLayer) path(p string) string {
+	return filepath.Join(l.dir, filepath.FromSlash(p))
+}
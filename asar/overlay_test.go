@@ -0,0 +1,56 @@
+package asar
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	base := packTree(t, testTree(t), CreateOptions{UnpackDir: "assets"})
+	patchSrc := t.TempDir()
+	writeTree(t, patchSrc, map[string]string{"dir/sub/c.js": "patched", "a.txt": "from patch"})
+	patch := packTree(t, patchSrc, CreateOptions{})
+	overrides := t.TempDir()
+	writeTree(t, overrides, map[string]string{"a.txt": "override", "extra.txt": "extra", "dir/.wh.b.txt": ""})
+
+	o, err := OpenOverlay(overrides, patch, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"a.txt":          "override",
+		"extra.txt":      "extra",
+		"dir/sub/c.js":   "patched",
+		"dir/run.sh":     "#!/bin/sh\necho hi\n",
+		"linkdir/run.sh": "#!/bin/sh\necho hi\n",
+		"assets/img.png": strings.Repeat("\x89PNG", 1024),
+	} {
+		if got, err := o.ReadFile(name); err != nil || string(got) != want {
+			t.Errorf("%s = %.20q, %v; want %.20q", name, got, err, want)
+		}
+	}
+	// 白障隐藏下层的 dir/b.txt，指向它的链接也随之失效
+	for _, name := range []string{"dir/b.txt", "link.txt", "dir/.wh.b.txt"} {
+		if _, err := o.ReadFile(name); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: err = %v, want ErrNotFound", name, err)
+		}
+	}
+	entries, err := o.ReadDir("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"run.sh", "sub"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ReadDir(dir) = %q, want %q", names, want)
+	}
+	files := o.ListFiles()
+	if !slices.Contains(files, "/extra.txt") || slices.Contains(files, "/dir/b.txt") {
+		t.Errorf("ListFiles = %q", files)
+	}
+}
@@ -2,6 +2,8 @@ package asar
 
 import (
 	"encoding/binary"
+	"errors"
+	"strconv"
 )
 
 const (
@@ -37,20 +39,26 @@ func NewEmptyPickle() *Pickle {
 	return p
 }
 
-// NewPickleFromBuffer 从完整的 Pickle 缓冲读取结构（含 header 和 payload）
-func NewPickleFromBuffer(buf []byte) *Pickle {
+// NewPickleFromBuffer 从完整的 Pickle 缓冲读取结构（含 header 和 payload），
+// 缓冲不足 4 字节或声明的 payload 大小与缓冲不符时返回错误
+func NewPickleFromBuffer(buf []byte) (*Pickle, error) {
+	if len(buf) < sizeUint32 {
+		return nil, errors.New("pickle: buffer of " + strconv.Itoa(len(buf)) + " bytes is too short for the payload size")
+	}
 	p := &Pickle{
 		header: buf,
 	}
-	payload := int(p.getPayloadSize())
+	payload := p.getPayloadSize()
 	p.headerSize = len(buf) - payload
-	if p.headerSize < 0 || p.headerSize != alignInt(p.headerSize, sizeUint32) {
-		p.headerSize = 0
-		p.header = []byte{}
+	if p.headerSize < sizeUint32 {
+		return nil, errors.New("pickle: payload size " + strconv.Itoa(payload) + " exceeds buffer size " + strconv.Itoa(len(buf)-sizeUint32))
+	}
+	if p.headerSize != alignInt(p.headerSize, sizeUint32) {
+		return nil, errors.New("pickle: misaligned header size " + strconv.Itoa(p.headerSize))
 	}
 	p.capacityAfterHeader = int(9_007_199_254_740_992) // CAPACITY_READ_ONLY
 	p.writeOffset = 0
-	return p
+	return p, nil
 }
 
 // ToBuffer 导出当前 Pickle 的有效字节切片
@@ -78,35 +86,50 @@ func (p *Pickle) NewIterator() *Iterator {
 }
 
 // ReadUInt32 读取一个 uint32（LE）
-func (it *Iterator) ReadUInt32() uint32 {
-	var v uint32
-	it.readBytes(sizeUint32, func(b []byte) { v = binary.LittleEndian.Uint32(b) })
-	return v
+func (it *Iterator) ReadUInt32() (uint32, error) {
+	b, err := it.readBytes(sizeUint32)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
 }
 
 // ReadInt32 读取一个 int32（LE）
-func (it *Iterator) ReadInt32() int32 {
-	var v int32
-	it.readBytes(sizeInt32, func(b []byte) { v = int32(binary.LittleEndian.Uint32(b)) })
-	return v
+func (it *Iterator) ReadInt32() (int32, error) {
+	b, err := it.readBytes(sizeInt32)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.LittleEndian.Uint32(b)), nil
 }
 
 // ReadString 读取一个以长度前缀的字符串（utf8）
-func (it *Iterator) ReadString() string {
-	l := int(it.ReadInt32())
-	var s []byte
-	it.readBytes(l, func(b []byte) { s = append([]byte(nil), b...) })
-	return string(s)
+func (it *Iterator) ReadString() (string, error) {
+	l, err := it.ReadInt32()
+	if err != nil {
+		return "", err
+	}
+	if l < 0 {
+		return "", errors.New("pickle: negative string length " + strconv.Itoa(int(l)))
+	}
+	b, err := it.readBytes(int(l))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
 }
 
-func (it *Iterator) readBytes(length int, fn func([]byte)) {
+// readBytes 读取 length 字节并按 4 字节对齐前进，剩余 payload 不足时返回错误
+func (it *Iterator) readBytes(length int) ([]byte, error) {
 	if length > it.endIndex-it.readIndex {
+		remaining := it.endIndex - it.readIndex
 		it.readIndex = it.endIndex
-		panic("pickle: insufficient payload to read")
+		return nil, errors.New("pickle: insufficient payload to read " + strconv.Itoa(length) + " bytes, " + strconv.Itoa(remaining) + " remaining")
 	}
 	off := it.payloadOffset + it.readIndex
-	fn(it.payload[off : off+length])
+	b := it.payload[off : off+length]
 	it.advance(length)
+	return b, nil
 }
 
 func (it *Iterator) advance(size int) {
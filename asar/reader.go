@@ -0,0 +1,174 @@
+package asar
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+)
+
+// Reader 持有归档的单个句柄，可重复读取其中的条目（类似 archive/zip.Reader）
+type Reader struct {
+	// File 为归档内全部条目，按路径排序（深度优先）
+	File []*File
+
+	fsys         *Filesystem
+	r            io.ReaderAt
+	size         int64
+	dataOffset   int64
+	closer       io.Closer
+	unpackedRoot string
+}
+
+// File 表示归档内的单个条目
+type File struct {
+	// Name 为归档内的相对路径，使用 '/' 分隔
+	Name string
+	// Entry 为对应的头条目（*FilesystemFileEntry、*FilesystemDirectoryEntry 或 *FilesystemLinkEntry）
+	Entry FilesystemEntry
+
+	reader *Reader
+}
+
+// OpenReader 打开归档文件并返回持有该句柄的 Reader，使用完毕后需调用 Close
+func OpenReader(archivePath string) (*Reader, error) {
+	return OpenReaderWithOptions(archivePath, ReadOptions{})
+}
+
+// OpenReaderWithOptions 按选项打开归档文件
+func OpenReaderWithOptions(archivePath string, options ReadOptions) (*Reader, error) {
+	abs, err := filepath.Abs(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r, err := NewReaderWithOptions(f, st.Size(), options)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.closer = f
+	r.unpackedRoot = abs + ".unpacked"
+	r.fsys.src = abs
+	return r, nil
+}
+
+// NewReader 从 io.ReaderAt 创建 Reader；由于没有归档路径，unpacked 条目无法打开
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	return NewReaderWithOptions(r, size, ReadOptions{})
+}
+
+// NewReaderWithOptions 按选项从 io.ReaderAt 创建 Reader
+func NewReaderWithOptions(r io.ReaderAt, size int64, options ReadOptions) (*Reader, error) {
+	header, err := readArchiveHeader(io.NewSectionReader(r, 0, size), size, options)
+	if err != nil {
+		return nil, err
+	}
+	rd := &Reader{
+		fsys:       &Filesystem{header: header.Header, headerSize: header.HeaderSize, invalid: header.Invalid},
+		r:          r,
+		size:       size,
+		dataOffset: int64(8 + header.HeaderSize),
+	}
+	err = walkEntries(header.Header, func(p string, e FilesystemEntry) error {
+		rd.File = append(rd.File, &File{Name: p, Entry: e, reader: rd})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rd, nil
+}
+
+// Close 关闭 OpenReader 打开的归档句柄；NewReader 创建的 Reader 不会关闭调用方的 io.ReaderAt
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	err := r.closer.Close()
+	r.closer = nil
+	return err
+}
+
+// Filesystem 返回归档头对应的 Filesystem
+func (r *Reader) Filesystem() *Filesystem { return r.fsys }
+
+// OpenFile 按归档内路径打开文件，符号链接会被解析
+func (r *Reader) OpenFile(name string) (io.ReadSeekCloser, error) {
+	entry, real, err := r.fsys.findNode(name, true)
+	if err != nil {
+		return nil, &PathError{Op: "open", Archive: r.fsys.src, Path: name, Err: err}
+	}
+	fe, ok := entry.(*FilesystemFileEntry)
+	if !ok {
+		return nil, &PathError{Op: "open", Archive: r.fsys.src, Path: name, Err: ErrNotFile}
+	}
+	return r.openEntry(real, fe)
+}
+
+// FileInfo 返回条目的 fs.FileInfo
+func (f *File) FileInfo() fs.FileInfo {
+	return &fileInfo{name: path.Base(f.Name), entry: f.Entry}
+}
+
+// Mode 返回条目的权限与类型位
+func (f *File) Mode() fs.FileMode { return f.FileInfo().Mode() }
+
+// Open 打开文件内容；符号链接会被解析，目录返回错误
+func (f *File) Open() (io.ReadSeekCloser, error) {
+	if fe, ok := f.Entry.(*FilesystemFileEntry); ok {
+		return f.reader.openEntry(f.Name, fe)
+	}
+	return f.reader.OpenFile(f.Name)
+}
+
+// openEntry 打开文件条目：打包内容返回共享句柄上的 SectionReader，unpacked 内容从 .unpacked 目录读取，
+// 压缩存储的内容边读边解压，只有向后 Seek 或调用 ReadAt 时才将解压后的完整内容读入内存
+func (r *Reader) openEntry(name string, fe *FilesystemFileEntry) (io.ReadSeekCloser, error) {
+	if fe.Unpacked {
+		if r.unpackedRoot == "" {
+			return nil, errors.New(name + ": unpacked file is not available without an archive path")
+		}
+		p, err := unpackedPath(r.fsys.src, name)
+		if err != nil {
+			return nil, err
+		}
+		return os.Open(p)
+	}
+	// 与 OpenFileSync 一致：空文件可以没有 offset
+	off, err := strconv.ParseInt(fe.Offset, 10, 64)
+	if err != nil && fe.Size > 0 {
+		return nil, &PathError{Op: "open", Archive: r.fsys.src, Path: name, Err: corruptHeader("invalid offset \""+fe.Offset+"\"", nil)}
+	}
+	start := r.dataOffset + off
+	if off < 0 || fe.Size < 0 || start+int64(fe.Size) > r.size {
+		return nil, &PathError{Op: "open", Archive: r.fsys.src, Path: name, Err: corruptHeader("file data is out of the archive bounds", nil)}
+	}
+	sec := io.NewSectionReader(r.r, start, int64(fe.Size))
+	if fe.Compression != nil {
+		cr, err := newCompressedFileReader(sectionReadCloser{sec}, sec.Size(), fe.Compression)
+		if err != nil {
+			return nil, &PathError{Op: "open", Archive: r.fsys.src, Path: name, Err: err}
+		}
+		return cr, nil
+	}
+	return sectionReadCloser{sec}, nil
+}
+
+// sectionReadCloser 为 SectionReader 提供空操作的 Close
+type sectionReadCloser struct {
+	*io.SectionReader
+}
+
+func (sectionReadCloser) Close() error { return nil }
@@ -0,0 +1,84 @@
+package asar
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReaderRoundTrip(t *testing.T) {
+	src := testTree(t)
+	archive := packTree(t, src, CreateOptions{UnpackDir: "assets", Compress: "*.js"})
+	r, err := OpenReader(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	files := 0
+	for _, f := range r.File {
+		if _, ok := f.Entry.(*FilesystemFileEntry); !ok {
+			continue
+		}
+		files++
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(f.Name, err)
+		}
+		got, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(f.Name, err)
+		}
+		want, _ := os.ReadFile(filepath.Join(src, filepath.FromSlash(f.Name)))
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: content differs", f.Name)
+		}
+		if f.FileInfo().Size() != int64(len(want)) {
+			t.Errorf("%s: size %d, want %d", f.Name, f.FileInfo().Size(), len(want))
+		}
+	}
+	if files != 6 {
+		t.Fatalf("read %d files, want 6", files)
+	}
+	// 符号链接经 OpenFile 解析
+	rc, err := r.OpenFile("linkdir/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if got, _ := io.ReadAll(rc); string(got) != "world" {
+		t.Fatalf("linkdir/b.txt = %q", got)
+	}
+}
+
+func TestReaderEmptyFileWithoutOffset(t *testing.T) {
+	var buf bytes.Buffer
+	root := &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{
+		"empty": &FilesystemFileEntry{},
+	}}
+	if err := writeFilesystemHeader(&buf, root); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(t.TempDir(), "app.asar")
+	if err := os.WriteFile(archive, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenReader(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	rc, err := r.OpenFile("empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+	fsys, _ := loadFilesystem(archive, ReadOptions{})
+	f, err := OpenFileSync(fsys, "empty", root.Files["empty"].(*FilesystemFileEntry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
@@ -0,0 +1,159 @@
+package asar
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReadOptions 读取归档头的选项
+type ReadOptions struct {
+	// Strict 为 true 时头中存在任何不合法条目即拒绝整个归档；
+	// 默认丢弃不合法条目（连同其子树），并通过 ArchiveHeader.Invalid 与 Filesystem.InvalidEntries 报告
+	Strict bool
+	// 以下限制超出时返回 *LimitError；0 表示使用对应的 Default* 值，负数表示不限制
+	// MaxHeaderSize 头 pickle 的最大字节数
+	MaxHeaderSize int
+	// MaxEntries 头中条目（文件、目录与链接）的最大数量
+	MaxEntries int
+	// MaxDepth 目录的最大嵌套层数
+	MaxDepth int
+	// MaxTotalSize 全部文件声明大小之和的上限（含 unpacked 文件）
+	MaxTotalSize int64
+}
+
+// InvalidEntryError 描述头中不合法的条目
+type InvalidEntryError struct {
+	// Path 为条目在归档内的路径（名称按原样拼接，可能包含非法字符）
+	Path   string
+	Reason string
+
+	escapes bool // 链接越出归档根目录
+}
+
+func (e *InvalidEntryError) Error() string {
+	return "invalid entry " + strconv.Quote(e.Path) + ": " + e.Reason
+}
+
+// Is 使 errors.Is 可匹配 ErrCorruptHeader，越界链接同时匹配 ErrLinkEscapes
+func (e *InvalidEntryError) Is(target error) bool {
+	return target == ErrCorruptHeader || e.escapes && target == ErrLinkEscapes
+}
+
+// validateHeader 校验头并移除不合法条目，返回按路径排序的问题列表；
+// dataSize 为数据区字节数，小于 0 表示未知，此时不检查偏移是否越界
+func validateHeader(root *FilesystemDirectoryEntry, dataSize int64) []*InvalidEntryError {
+	var invalid []*InvalidEntryError
+	var walk func(dir *FilesystemDirectoryEntry, prefix string)
+	walk = func(dir *FilesystemDirectoryEntry, prefix string) {
+		names := make([]string, 0, len(dir.Files))
+		for name := range dir.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p := name
+			if prefix != "" {
+				p = prefix + "/" + name
+			}
+			child := dir.Files[name]
+			reason := validateEntryName(name)
+			if reason == "" {
+				reason = validateEntry(child, dataSize)
+			}
+			if reason != "" {
+				lnk, isLink := child.(*FilesystemLinkEntry)
+				invalid = append(invalid, &InvalidEntryError{Path: p, Reason: reason, escapes: isLink && linkTargetEscapes(lnk.Link)})
+				delete(dir.Files, name)
+				continue
+			}
+			if d, ok := child.(*FilesystemDirectoryEntry); ok {
+				walk(d, p)
+			}
+		}
+	}
+	walk(root, "")
+	return invalid
+}
+
+// validateEntryName 检查条目名称是否为单个合法的路径段
+func validateEntryName(name string) string {
+	switch {
+	case name == "":
+		return "empty name"
+	case name == "." || name == "..":
+		return "reserved name"
+	case strings.ContainsAny(name, "/\\"):
+		return "name contains a path separator"
+	case strings.IndexByte(name, 0) >= 0:
+		return "name contains a NUL byte"
+	}
+	return ""
+}
+
+// validateEntry 检查条目的字段取值
+func validateEntry(entry FilesystemEntry, dataSize int64) string {
+	switch e := entry.(type) {
+	case *FilesystemDirectoryEntry:
+		if e.Files == nil {
+			e.Files = map[string]FilesystemEntry{}
+		}
+	case *FilesystemLinkEntry:
+		return validateLinkTarget(e.Link)
+	case *FilesystemFileEntry:
+		if e.Size < 0 {
+			return "negative size " + strconv.Itoa(e.Size)
+		}
+		if c := e.Compression; c != nil {
+			switch {
+			case e.Unpacked:
+				return "unpacked file can not be compressed"
+			case !supportedCompression(c.Algorithm):
+				return "unsupported compression algorithm " + strconv.Quote(c.Algorithm)
+			case c.Size < 0:
+				return "negative uncompressed size " + strconv.Itoa(c.Size)
+			}
+		}
+		if e.Unpacked {
+			return ""
+		}
+		if e.Offset == "" && e.Size == 0 {
+			return ""
+		}
+		off, err := strconv.ParseInt(e.Offset, 10, 64)
+		if err != nil || off < 0 || strings.TrimLeft(e.Offset, "0123456789") != "" {
+			return "invalid offset " + strconv.Quote(e.Offset)
+		}
+		if dataSize >= 0 && (off > dataSize || int64(e.Size) > dataSize-off) {
+			return "file data is out of the archive bounds"
+		}
+	default:
+		return "unknown entry type"
+	}
+	return ""
+}
+
+// validateLinkTarget 检查链接目标是否为不越出归档根目录的相对路径
+func validateLinkTarget(link string) string {
+	if link == "" {
+		return "empty link target"
+	}
+	if strings.IndexByte(link, 0) >= 0 {
+		return "link target contains a NUL byte"
+	}
+	if linkTargetEscapes(link) {
+		return "link target " + strconv.Quote(link) + " " + ErrLinkEscapes.Error()
+	}
+	return ""
+}
+
+// linkTargetEscapes 判断链接目标是否为绝对路径或越出归档根目录
+func linkTargetEscapes(link string) bool {
+	slash := strings.ReplaceAll(link, "\\", "/")
+	if path.IsAbs(slash) || (len(slash) >= 2 && slash[1] == ':') {
+		return true
+	}
+	clean := path.Clean(slash)
+	return clean == ".." || strings.HasPrefix(clean, "../")
+}
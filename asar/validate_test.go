@@ -0,0 +1,55 @@
+package asar
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidateHeader(t *testing.T) {
+	const ok = `"ok.txt":{"size":5,"offset":"0"}`
+	for _, tt := range []struct {
+		name  string
+		entry string
+	}{
+		{"separator", `"a/b":{"size":5,"offset":"0"}`},
+		{"parent", `"..":{"size":5,"offset":"0"}`},
+		{"parent path", `"../evil":{"size":5,"offset":"0"}`},
+		{"NUL", `"a\u0000b":{"size":5,"offset":"0"}`},
+		{"empty name", `"":{"size":5,"offset":"0"}`},
+		{"negative size", `"neg":{"size":-1,"offset":"0"}`},
+		{"non-numeric offset", `"off":{"size":5,"offset":"abc"}`},
+		{"out-of-range offset", `"far":{"size":5,"offset":"100"}`},
+		{"absolute link", `"abs":{"link":"/etc/passwd"}`},
+		{"escaping link", `"up":{"link":"../outside"}`},
+		{"escaping link in directory", `"d":{"files":{"up":{"link":"d/../../outside"}}}`},
+	} {
+		dir := t.TempDir()
+		archive := filepath.Join(dir, "app.asar")
+		data := append(rawArchive(`{"files":{`+ok+`,`+tt.entry+`}}`), "hello"...)
+		if err := os.WriteFile(archive, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var ie *InvalidEntryError
+		if _, err := loadFilesystem(archive, ReadOptions{Strict: true}); !errors.As(err, &ie) || !errors.Is(err, ErrCorruptHeader) {
+			t.Errorf("%s: strict read err = %v, want *InvalidEntryError", tt.name, err)
+		}
+		// 非严格模式丢弃该条目，其余内容照常解压且不会写到 dest 之外
+		if err := ExtractAll(archive, filepath.Join(dir, "out")); err != nil {
+			t.Errorf("%s: ExtractAll: %v", tt.name, err)
+			continue
+		}
+		got := snapshotDir(t, dir)
+		delete(got, ".")
+		delete(got, "app.asar")
+		want := map[string]string{"out": "d---------", "out/ok.txt": "hello"}
+		if tt.name == "escaping link in directory" {
+			want["out/d"] = "d---------"
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: extracted %v, want %v", tt.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package asar
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxFileSize 单个打包文件的最大字节数（offset/size 以 uint32 兼容方式记录）
+const maxFileSize = int64(^uint32(0))
+
+// Writer 以编程方式构建 ASAR 归档，生成的头、偏移与完整性信息与目录打包一致。
+// ASAR 的头位于数据之前且包含各文件的完整性哈希，因此文件数据会先暂存到临时文件，
+// 在 Close 时依次写出头与数据
+type Writer struct {
+	w       io.Writer
+	root    *FilesystemDirectoryEntry
+	spool   *os.File
+	offset  int64
+	current *fileWriter
+	closed  bool
+}
+
+// NewWriter 创建写入到 w 的 Writer；Close 不会关闭 w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:    w,
+		root: &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{}},
+	}
+}
+
+// CreateFile 添加大小已知的文件，返回的 io.Writer 必须恰好写入 size 字节，
+// 且需在下一次调用 CreateFile、Create、Mkdir、Symlink 或 Close 之前写完
+func (w *Writer) CreateFile(name string, size int64, mode fs.FileMode) (io.Writer, error) {
+	if size < 0 {
+		return nil, errors.New(name + ": negative file size")
+	}
+	if size > maxFileSize {
+		return nil, &PathError{Op: "create", Path: name, Err: ErrFileTooLarge}
+	}
+	return w.create(name, size, mode)
+}
+
+// Create 添加大小未知的文件，内容暂存到临时存储，写完后再确定大小
+func (w *Writer) Create(name string, mode fs.FileMode) (io.Writer, error) {
+	return w.create(name, -1, mode)
+}
+
+// Mkdir 添加目录，缺失的上级目录会自动创建；目录已存在时不报错
+func (w *Writer) Mkdir(name string) error {
+	if err := w.finishFile(); err != nil {
+		return err
+	}
+	dir, base, err := w.parent(name)
+	if err != nil {
+		return err
+	}
+	if base == "" {
+		return nil
+	}
+	if existing, ok := dir.Files[base]; ok {
+		if _, isDir := existing.(*FilesystemDirectoryEntry); isDir {
+			return nil
+		}
+		return errors.New(name + ": entry already exists")
+	}
+	dir.Files[base] = &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{}}
+	return nil
+}
+
+// Symlink 添加符号链接，target 与 os.Symlink 一样相对于链接所在目录，且不能指向归档之外
+func (w *Writer) Symlink(name, target string) error {
+	if err := w.finishFile(); err != nil {
+		return err
+	}
+	dir, base, err := w.parent(name)
+	if err != nil {
+		return err
+	}
+	if base == "" {
+		return errors.New(name + ": invalid entry name")
+	}
+	if _, ok := dir.Files[base]; ok {
+		return errors.New(name + ": entry already exists")
+	}
+	if path.IsAbs(target) {
+		return &PathError{Op: "symlink", Path: name, Err: linkEscapes(target)}
+	}
+	link := path.Join(path.Dir(cleanEntryName(name)), target)
+	if hasParentOutOf(link) {
+		return &PathError{Op: "symlink", Path: name, Err: linkEscapes(link)}
+	}
+	dir.Files[base] = &FilesystemLinkEntry{Link: link}
+	return nil
+}
+
+// Close 写出头与暂存的数据并清理临时文件
+func (w *Writer) Close() error {
+	if w.closed {
+		return errors.New("asar: writer already closed")
+	}
+	w.closed = true
+	defer w.removeSpool()
+	if err := w.finishFile(); err != nil {
+		return err
+	}
+	if err := writeFilesystemHeader(w.w, w.root); err != nil {
+		return err
+	}
+	if w.spool == nil {
+		return nil
+	}
+	_, err := copyBuffer(w.w, io.NewSectionReader(w.spool, 0, w.offset))
+	return err
+}
+
+func (w *Writer) create(name string, size int64, mode fs.FileMode) (io.Writer, error) {
+	if err := w.finishFile(); err != nil {
+		return nil, err
+	}
+	dir, base, err := w.parent(name)
+	if err != nil {
+		return nil, err
+	}
+	if base == "" {
+		return nil, errors.New(name + ": invalid entry name")
+	}
+	if _, ok := dir.Files[base]; ok {
+		return nil, errors.New(name + ": entry already exists")
+	}
+	if w.spool == nil {
+		spool, err := os.CreateTemp("", "asar-spool-*")
+		if err != nil {
+			return nil, err
+		}
+		w.spool = spool
+	}
+	entry := &FilesystemFileEntry{Executable: executableMode(mode)}
+	dir.Files[base] = entry
+	w.current = &fileWriter{w: w, name: name, entry: entry, start: w.offset, size: size, integrity: NewIntegrityWriter()}
+	return w.current, nil
+}
+
+// finishFile 结束当前文件：校验大小并记录偏移与写入时计算的完整性
+func (w *Writer) finishFile() error {
+	fw := w.current
+	if fw == nil {
+		return nil
+	}
+	w.current = nil
+	if fw.size >= 0 && fw.written != fw.size {
+		return errors.New(fw.name + ": wrote " + strconv.FormatInt(fw.written, 10) + " bytes, expected " + strconv.FormatInt(fw.size, 10))
+	}
+	if fw.written > maxFileSize {
+		return &PathError{Op: "create", Path: fw.name, Err: ErrFileTooLarge}
+	}
+	fw.entry.Size = int(fw.written)
+	fw.entry.Offset = strconv.FormatInt(fw.start, 10)
+	fw.entry.Integrity = fw.integrity.Integrity()
+	w.offset += fw.written
+	return nil
+}
+
+// parent 返回 name 所在目录（按需创建）与末级名称
+func (w *Writer) parent(name string) (*FilesystemDirectoryEntry, string, error) {
+	if w.closed {
+		return nil, "", errors.New("asar: writer already closed")
+	}
+	return entryParent(w.root, name)
+}
+
+// entryParent 在 root 中返回 name 所在目录（按需创建）与末级名称；name 为根目录时末级名称为空
+func entryParent(root *FilesystemDirectoryEntry, name string) (*FilesystemDirectoryEntry, string, error) {
+	clean := cleanEntryName(name)
+	if clean == "." {
+		return root, "", nil
+	}
+	if hasParentOutOf(clean) {
+		return nil, "", errors.New(name + ": invalid entry name")
+	}
+	parts := strings.Split(clean, "/")
+	cur := root
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := cur.Files[part]
+		if !ok {
+			d := &FilesystemDirectoryEntry{Files: map[string]FilesystemEntry{}, EntryMetadata: EntryMetadata{Unpacked: cur.Unpacked}}
+			cur.Files[part] = d
+			cur = d
+			continue
+		}
+		d, isDir := next.(*FilesystemDirectoryEntry)
+		if !isDir {
+			return nil, "", errors.New(name + ": parent is not a directory")
+		}
+		cur = d
+	}
+	return cur, parts[len(parts)-1], nil
+}
+
+func (w *Writer) removeSpool() {
+	if w.spool == nil {
+		return
+	}
+	w.spool.Close()
+	os.Remove(w.spool.Name())
+	w.spool = nil
+}
+
+// cleanEntryName 将归档内路径规范化为不带前导 '/' 的 slash 路径
+func cleanEntryName(name string) string {
+	return path.Clean(strings.TrimPrefix(strings.ReplaceAll(name, "\\", "/"), "/"))
+}
+
+// fileWriter 为当前正在写入的文件，数据直接追加到暂存文件并同时计算完整性
+type fileWriter struct {
+	w         *Writer
+	name      string
+	entry     *FilesystemFileEntry
+	start     int64
+	size      int64
+	written   int64
+	integrity *IntegrityWriter
+}
+
+func (fw *fileWriter) Write(p []byte) (int, error) {
+	if fw.w.current != fw {
+		return 0, errors.New(fw.name + ": write after entry was closed")
+	}
+	if fw.size >= 0 && fw.written+int64(len(p)) > fw.size {
+		return 0, errors.New(fw.name + ": write exceeds declared size")
+	}
+	n, err := fw.w.spool.WriteAt(p, fw.start+fw.written)
+	fw.integrity.Write(p[:n])
+	fw.written += int64(n)
+	return n, err
+}
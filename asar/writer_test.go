@@ -0,0 +1,79 @@
+package asar
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestWriterMatchesDirectoryPack 按遍历顺序用 Writer 写出同一目录，结果应与目录打包逐字节相同
+func TestWriterMatchesDirectoryPack(t *testing.T) {
+	src := testTree(t)
+	archive := packTree(t, src, CreateOptions{})
+	want, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	err = filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == src {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		name := filepath.ToSlash(rel)
+		info, _ := os.Lstat(p)
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, _ := os.Readlink(p)
+			return w.Symlink(name, target)
+		case info.IsDir():
+			return w.Mkdir(name)
+		}
+		data, _ := os.ReadFile(p)
+		// 交替使用大小已知与未知两种方式
+		var fw io.Writer
+		if strings.HasSuffix(name, ".js") {
+			fw, err = w.Create(name, info.Mode())
+		} else {
+			fw, err = w.CreateFile(name, int64(len(data)), info.Mode())
+		}
+		if err != nil {
+			return err
+		}
+		_, err = fw.Write(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatal("Writer output differs from directory pack")
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	w := NewWriter(io.Discard)
+	fw, err := w.CreateFile("a.txt", 5, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("abc"))
+	if _, err := w.CreateFile("b.txt", 1, 0o644); err == nil {
+		t.Fatal("short write was not reported")
+	}
+	w.Close()
+	w = NewWriter(io.Discard)
+	if err := w.Symlink("link", "../outside"); !errors.Is(err, ErrLinkEscapes) {
+		t.Fatalf("err = %v, want ErrLinkEscapes", err)
+	}
+	w.Close()
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/dcboy/go-asar/asar"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 )
 
 // main 解析命令并对齐 node-asar 的子命令与参数
@@ -19,12 +24,20 @@ func main() {
 	switch cmd {
 	case "pack", "p":
 		// 手工解析，支持选项与位置参数交错
-		dir, output, opts := parsePackArgs(os.Args[2:])
-		if dir == "" || output == "" {
+		dir, output, opts, err := parsePackArgs(os.Args[2:])
+		if err != nil {
+			fmt.Println(err)
+		}
+		if err != nil || dir == "" || output == "" {
 			fmt.Println("用法: asar pack <dir> <output> [options]")
 			os.Exit(1)
 		}
-		if err := asar.CreatePackageWithOptions(dir, output, opts); err != nil {
+		// 收到中断信号时取消打包，临时文件会被清理，不会留下不完整的归档
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		opts.Context = ctx
+		err = asar.CreatePackageWithOptions(dir, output, opts)
+		stop()
+		if err != nil {
 			fmt.Println("打包失败:", err)
 			os.Exit(1)
 		}
@@ -66,37 +79,230 @@ func main() {
 	case "extract", "e":
 		// extract <archive> <dest>
 		fs := flag.NewFlagSet("extract", flag.ExitOnError)
+		strict := fs.Bool("strict", false, "头中存在不合法条目时拒绝解压")
 		_ = fs.Parse(os.Args[2:])
 		args := fs.Args()
 		if len(args) < 2 {
-			fmt.Println("用法: asar extract <archive> <dest>")
+			fmt.Println("用法: asar extract [--strict] <archive> <dest>")
 			os.Exit(1)
 		}
 		archive := args[0]
 		dest := args[1]
-		if err := asar.ExtractAll(archive, dest); err != nil {
+		if err := asar.ExtractAllWithOptions(archive, dest, asar.ReadOptions{Strict: *strict}); err != nil {
 			fmt.Println("解压失败:", err)
 			os.Exit(1)
 		}
 		fmt.Println("解压完成:", dest)
+	case "add":
+		// add <archive> <file> <path>
+		args := os.Args[2:]
+		if len(args) < 3 {
+			fmt.Println("用法: asar add <archive> <file> <path>")
+			os.Exit(1)
+		}
+		if err := addFile(args[0], args[1], args[2]); err != nil {
+			fmt.Println("添加失败:", err)
+			os.Exit(1)
+		}
+		fmt.Println("添加完成:", args[2])
+	case "rm":
+		// rm <archive> <path>
+		args := os.Args[2:]
+		if len(args) < 2 {
+			fmt.Println("用法: asar rm <archive> <path>")
+			os.Exit(1)
+		}
+		if err := removeEntry(args[0], args[1]); err != nil {
+			fmt.Println("删除失败:", err)
+			os.Exit(1)
+		}
+		fmt.Println("删除完成:", args[1])
+	case "compact":
+		// compact <archive> [output] [--order header|size] [--ordering <file>]
+		archive, output, opts, err := parseCompactArgs(os.Args[2:])
+		if err != nil || archive == "" {
+			fmt.Println("用法: asar compact <archive> [output] [--order header|size] [--ordering <file>]")
+			os.Exit(1)
+		}
+		if output == "" {
+			output = archive
+		}
+		res, err := asar.Compact(archive, output, opts)
+		if err != nil {
+			fmt.Println("压缩失败:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("压缩完成: %s（%d -> %d 字节，回收 %d 字节）\n", filepath.Base(output), res.OldSize, res.NewSize, res.Reclaimed)
+	case "diff":
+		// diff <old> <new> [--json] [打包选项]，任一侧可为目录
+		oldPath, newPath, opts, err := parsePackArgs(os.Args[2:])
+		if err != nil {
+			fmt.Println(err)
+		}
+		if err != nil || oldPath == "" || newPath == "" {
+			fmt.Println("用法: asar diff <old> <new> [--json] [--unpack --unpack-dir --pattern --include --exclude --exclude-hidden]")
+			os.Exit(1)
+		}
+		diffs, err := asar.Diff(oldPath, newPath, asar.DiffOptions{Pack: opts})
+		if err != nil {
+			fmt.Println("比较失败:", err)
+			os.Exit(1)
+		}
+		if contains(os.Args[2:], "--json") {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			_ = enc.Encode(diffs)
+			return
+		}
+		for _, d := range diffs {
+			printDiff(d)
+		}
+	case "delta":
+		// delta <old> <new> <patch>
+		args := os.Args[2:]
+		if len(args) < 3 {
+			fmt.Println("用法: asar delta <old> <new> <patch>")
+			os.Exit(1)
+		}
+		if err := createDelta(args[0], args[1], args[2]); err != nil {
+			fmt.Println("生成补丁失败:", err)
+			os.Exit(1)
+		}
+		fmt.Println("补丁已生成:", filepath.Base(args[2]))
+	case "apply":
+		// apply <old> <patch> <output>
+		args := os.Args[2:]
+		if len(args) < 3 {
+			fmt.Println("用法: asar apply <old> <patch> <output>")
+			os.Exit(1)
+		}
+		if err := applyDelta(args[0], args[1], args[2]); err != nil {
+			fmt.Println("应用补丁失败:", err)
+			os.Exit(1)
+		}
+		fmt.Println("应用完成:", filepath.Base(args[2]))
+	case "merge":
+		// merge <output> <archive[=prefix]>... [--conflict error|first|last]
+		output, sources, opts, err := parseMergeArgs(os.Args[2:])
+		if err != nil || output == "" || len(sources) == 0 {
+			fmt.Println("用法: asar merge <output> <archive[=prefix]>... [--conflict error|first|last]")
+			os.Exit(1)
+		}
+		if err := asar.Merge(output, sources, opts); err != nil {
+			fmt.Println("合并失败:", err)
+			os.Exit(1)
+		}
+		fmt.Println("合并完成:", filepath.Base(output))
 	default:
 		printHelp()
 		os.Exit(1)
 	}
 }
 
+// createDelta 生成补丁文件，失败时删除不完整的补丁
+func createDelta(oldPath, newPath, patchPath string) error {
+	f, err := os.Create(patchPath)
+	if err != nil {
+		return err
+	}
+	err = asar.CreateDelta(oldPath, newPath, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(patchPath)
+	}
+	return err
+}
+
+// applyDelta 将补丁文件应用到旧归档
+func applyDelta(oldPath, patchPath, output string) error {
+	f, err := os.Open(patchPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return asar.ApplyDelta(oldPath, f, output)
+}
+
+// printDiff 以文本形式输出一处差异，类型、模式与解包状态的变化附带前后取值（哈希与链接目标见 --json）
+func printDiff(d asar.DiffEntry) {
+	switch d.Kind {
+	case asar.DiffTypeChanged, asar.DiffModeChanged, asar.DiffUnpackChanged:
+		fmt.Printf("%-15s /%s (%s -> %s)\n", d.Kind, d.Path, d.Old, d.New)
+	default:
+		fmt.Printf("%-15s /%s\n", d.Kind, d.Path)
+	}
+}
+
+// contains 判断 args 中是否包含 flag
+func contains(args []string, flag string) bool {
+	for _, a := range args {
+		if a == flag {
+			return true
+		}
+	}
+	return false
+}
+
 // printHelp 打印简单帮助
 func printHelp() {
 	fmt.Println("用法:")
-	fmt.Println("  asar pack <dir> <output> [--ordering --unpack --unpack-dir --exclude-hidden]")
+	fmt.Println("  asar pack <dir> <output> [--ordering --unpack --unpack-dir --compress --compression gzip|deflate --pattern --include --exclude --exclude-hidden --concurrency]")
 	fmt.Println("  asar list <archive> [-i | --is-pack]")
 	fmt.Println("  asar extract-file <archive> <filename>")
-	fmt.Println("  asar extract <archive> <dest>")
+	fmt.Println("  asar extract [--strict] <archive> <dest>")
+	fmt.Println("  asar add <archive> <file> <path>")
+	fmt.Println("  asar rm <archive> <path>")
+	fmt.Println("  asar compact <archive> [output] [--order header|size] [--ordering <file>]")
+	fmt.Println("  asar diff <old> <new> [--json] [--unpack --unpack-dir --pattern --include --exclude --exclude-hidden]")
+	fmt.Println("  asar delta <old> <new> <patch>")
+	fmt.Println("  asar apply <old> <patch> <output>")
+	fmt.Println("  asar merge <output> <archive[=prefix]>... [--conflict error|first|last]")
 }
 
-// parsePackArgs 解析 pack 子命令参数，支持交错
-func parsePackArgs(argv []string) (string, string, asar.CreateOptions) {
+// addFile 将本地文件写入归档内的 path，已存在的文件会被替换
+func addFile(archive, file, p string) error {
+	st, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if !st.Mode().IsRegular() {
+		return fmt.Errorf("%s: 不是普通文件", file)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ed, err := asar.Edit(archive)
+	if err != nil {
+		return err
+	}
+	defer ed.Close()
+	if err := ed.AddFile(p, f, st.Mode()); err != nil {
+		return err
+	}
+	return ed.Commit()
+}
+
+// removeEntry 从归档中删除 path（目录连同其内容）
+func removeEntry(archive, p string) error {
+	ed, err := asar.Edit(archive)
+	if err != nil {
+		return err
+	}
+	defer ed.Close()
+	if err := ed.Remove(p); err != nil {
+		return err
+	}
+	return ed.Commit()
+}
+
+// parsePackArgs 解析 pack 子命令参数，支持交错；--unpack、--unpack-dir、--compress、--include 与 --exclude 可重复传入
+func parsePackArgs(argv []string) (string, string, asar.CreateOptions, error) {
 	var dir, output string
+	var unpack, unpackDir, compress []string
 	opts := asar.CreateOptions{Dot: true}
 	for i := 0; i < len(argv); i++ {
 		a := argv[i]
@@ -104,10 +310,32 @@ func parsePackArgs(argv []string) (string, string, asar.CreateOptions) {
 			opts.Ordering = argv[i+1]
 			i++
 		} else if a == "--unpack" && i+1 < len(argv) {
-			opts.Unpack = argv[i+1]
+			unpack = append(unpack, argv[i+1])
 			i++
 		} else if a == "--unpack-dir" && i+1 < len(argv) {
-			opts.UnpackDir = argv[i+1]
+			unpackDir = append(unpackDir, argv[i+1])
+			i++
+		} else if a == "--compress" && i+1 < len(argv) {
+			compress = append(compress, argv[i+1])
+			i++
+		} else if a == "--compression" && i+1 < len(argv) {
+			opts.Compression = argv[i+1]
+			i++
+		} else if a == "--include" && i+1 < len(argv) {
+			opts.Include = append(opts.Include, argv[i+1])
+			i++
+		} else if a == "--pattern" && i+1 < len(argv) {
+			opts.Pattern = argv[i+1]
+			i++
+		} else if a == "--exclude" && i+1 < len(argv) {
+			opts.Exclude = append(opts.Exclude, argv[i+1])
+			i++
+		} else if a == "--concurrency" && i+1 < len(argv) {
+			n, err := strconv.Atoi(argv[i+1])
+			if err != nil || n < 0 {
+				return "", "", opts, fmt.Errorf("无效的并发数: %s", argv[i+1])
+			}
+			opts.Concurrency = n
 			i++
 		} else if a == "--exclude-hidden" {
 			opts.Dot = false
@@ -119,7 +347,80 @@ func parsePackArgs(argv []string) (string, string, asar.CreateOptions) {
 			output = a
 		}
 	}
-	return dir, output, opts
+	opts.Unpack = joinGlobs(unpack)
+	opts.UnpackDir = joinGlobs(unpackDir)
+	opts.Compress = joinGlobs(compress)
+	return dir, output, opts, nil
+}
+
+// joinGlobs 将多个 glob 合并为一个 minimatch 花括号集合，如 {*.node,*.dll}
+func joinGlobs(patterns []string) string {
+	switch len(patterns) {
+	case 0:
+		return ""
+	case 1:
+		return patterns[0]
+	}
+	return "{" + strings.Join(patterns, ",") + "}"
+}
+
+// parseCompactArgs 解析 compact 子命令参数，未给出 output 时就地压缩
+func parseCompactArgs(argv []string) (string, string, asar.CompactOptions, error) {
+	var archive, output string
+	var opts asar.CompactOptions
+	for i := 0; i < len(argv); i++ {
+		a := argv[i]
+		if a == "--order" && i+1 < len(argv) {
+			switch argv[i+1] {
+			case "header":
+				opts.Order = asar.CompactHeaderOrder
+			case "size":
+				opts.Order = asar.CompactSizeOrder
+			default:
+				return "", "", opts, fmt.Errorf("未知的排列方式: %s", argv[i+1])
+			}
+			i++
+		} else if a == "--ordering" && i+1 < len(argv) {
+			opts.Ordering = argv[i+1]
+			i++
+		} else if strings.HasPrefix(a, "-") {
+		} else if archive == "" {
+			archive = a
+		} else if output == "" {
+			output = a
+		}
+	}
+	return archive, output, opts, nil
+}
+
+// parseMergeArgs 解析 merge 子命令参数，来源写作 archive 或 archive=prefix
+func parseMergeArgs(argv []string) (string, []asar.MergeSource, asar.MergeOptions, error) {
+	var output string
+	var sources []asar.MergeSource
+	var opts asar.MergeOptions
+	for i := 0; i < len(argv); i++ {
+		a := argv[i]
+		if a == "--conflict" && i+1 < len(argv) {
+			switch argv[i+1] {
+			case "error":
+				opts.Conflict = asar.MergeConflictError
+			case "first":
+				opts.Conflict = asar.MergeFirstWins
+			case "last":
+				opts.Conflict = asar.MergeLastWins
+			default:
+				return "", nil, opts, fmt.Errorf("未知的冲突处理方式: %s", argv[i+1])
+			}
+			i++
+		} else if strings.HasPrefix(a, "-") {
+		} else if output == "" {
+			output = a
+		} else {
+			archive, prefix, _ := strings.Cut(a, "=")
+			sources = append(sources, asar.MergeSource{Archive: archive, Prefix: prefix})
+		}
+	}
+	return output, sources, opts, nil
 }
 
 // parseListArgs 解析 list 子命令参数